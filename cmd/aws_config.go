@@ -0,0 +1,164 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var awsConfigPath string
+
+// awsConfigCmd represents the aws-config command
+var awsConfigCmd = &cobra.Command{
+	Use:   "aws-config",
+	Short: "Writes AWS region from ECS task metadata to AWS shared config file",
+	Long: `Sets region of the [default] profile in AWS shared config file, for tools that
+read it from the config rather than AWS_REGION environment variable. Other
+settings and profiles of an existing file are kept intact.
+
+File defaults to $AWS_CONFIG_FILE, or $HOME/.aws/config when it's not set.
+Nothing is written when the region is unknown.`,
+	Args: cobra.NoArgs,
+	RunE: awsConfigCmdRunE,
+}
+
+// Returns path of AWS shared config file, the same AWS SDKs default to.
+func defaultAwsConfigPath() (string, error) {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// Returns AWS shared `config` with region of the [default] profile set to
+// `region`, adding the profile or the setting when missing.
+func setAwsConfigRegion(config, region string) string {
+	lines := strings.Split(strings.TrimSuffix(config, "\n"), "\n")
+	section := -1
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			if section >= 0 {
+				break
+			}
+
+			if line == "[default]" {
+				section = i
+			}
+
+			continue
+		}
+
+		if key, _, ok := strings.Cut(line, "="); section >= 0 && ok && strings.TrimSpace(key) == "region" {
+			lines[i] = "region = " + region
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+
+	if section < 0 {
+		if strings.TrimSpace(config) == "" {
+			return "[default]\nregion = " + region + "\n"
+		}
+
+		return strings.Join(lines, "\n") + "\n\n[default]\nregion = " + region + "\n"
+	}
+
+	lines = append(lines[:section+1], append([]string{"region = " + region}, lines[section+1:]...)...)
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Sets region of the [default] profile in AWS shared config file at `path`,
+// creating the file and its directory when missing.
+func writeAwsConfigRegion(path, region string) error {
+	config, err := os.ReadFile(path)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, setAwsConfigRegion(string(config), region))
+		return err
+	})
+}
+
+func awsConfigCmdRunE(cmd *cobra.Command, args []string) error {
+	metadata, err := getEcsTaskMetadata(cmd.Context(), newHTTPClient())
+
+	if err != nil {
+		slog.Error("Can't retrieve ECS task metadata", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	if metadata.AwsRegion == "" {
+		slog.Warn("AWS region is unknown, skipping AWS config")
+		return nil
+	}
+
+	path := awsConfigPath
+
+	if path == "" {
+		if path, err = defaultAwsConfigPath(); err != nil {
+			return err
+		}
+	}
+
+	if err := writeAwsConfigRegion(path, metadata.AwsRegion); err != nil {
+		slog.Error("Can't write AWS config", "path", path, "error", err)
+		return err
+	}
+
+	slog.Info("Wrote AWS config", "path", path, "region", metadata.AwsRegion)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(awsConfigCmd)
+
+	addMetadataEndpointFlags(awsConfigCmd)
+	addMetadataCacheFlags(awsConfigCmd)
+	awsConfigCmd.Flags().StringVar(&awsConfigPath, "path", "", "AWS shared config file to write (default $AWS_CONFIG_FILE or $HOME/.aws/config)")
+}
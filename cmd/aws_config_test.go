@@ -0,0 +1,93 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultAwsConfigPath(t *testing.T) {
+	t.Run("returns $AWS_CONFIG_FILE", func(t *testing.T) {
+		t.Setenv("AWS_CONFIG_FILE", "/etc/aws/config")
+
+		path, err := defaultAwsConfigPath()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "/etc/aws/config", path)
+	})
+
+	t.Run("falls back to $HOME/.aws/config", func(t *testing.T) {
+		t.Setenv("AWS_CONFIG_FILE", "")
+		t.Setenv("HOME", "/home/fluent-bit")
+
+		path, err := defaultAwsConfigPath()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "/home/fluent-bit/.aws/config", path)
+	})
+}
+
+func TestSetAwsConfigRegion(t *testing.T) {
+	t.Run("writes default profile into empty config", func(t *testing.T) {
+		assert.Equal(t, "[default]\nregion = aws-region-1\n", setAwsConfigRegion("", "aws-region-1"))
+	})
+
+	t.Run("replaces region of default profile", func(t *testing.T) {
+		config := "[profile other]\nregion = aws-region-2\n\n[default]\noutput = json\nregion=aws-region-2\n"
+
+		assert.Equal(t,
+			"[profile other]\nregion = aws-region-2\n\n[default]\noutput = json\nregion = aws-region-1\n",
+			setAwsConfigRegion(config, "aws-region-1"))
+	})
+
+	t.Run("adds region to default profile", func(t *testing.T) {
+		config := "[default]\noutput = json\n\n[profile other]\nregion = aws-region-2\n"
+
+		assert.Equal(t,
+			"[default]\nregion = aws-region-1\noutput = json\n\n[profile other]\nregion = aws-region-2\n",
+			setAwsConfigRegion(config, "aws-region-1"))
+	})
+
+	t.Run("adds default profile", func(t *testing.T) {
+		config := "[profile other]\nregion = aws-region-2\n"
+
+		assert.Equal(t,
+			"[profile other]\nregion = aws-region-2\n\n[default]\nregion = aws-region-1\n",
+			setAwsConfigRegion(config, "aws-region-1"))
+	})
+}
+
+func TestWriteAwsConfigRegion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".aws", "config")
+
+	assert.Nil(t, writeAwsConfigRegion(path, "aws-region-1"))
+	assert.Nil(t, writeAwsConfigRegion(path, "aws-region-2"))
+
+	config, err := os.ReadFile(path)
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, "[default]\nregion = aws-region-2\n", string(config))
+}
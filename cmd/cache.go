@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metadataCache     bool
+	metadataCachePath = filepath.Join(os.TempDir(), "ecs-task-metadata.json")
+	metadataCacheTTL  = 5 * time.Minute
+)
+
+// Cached task metadata along with the endpoint it was retrieved from. Endpoint
+// URI is unique per container of a task, so it tells whether the cache belongs
+// to the current task.
+type metadataCacheEntry struct {
+	Endpoint  string           `json:"endpoint"`
+	FetchedAt time.Time        `json:"fetched_at"`
+	Metadata  ecsmeta.Metadata `json:"metadata"`
+}
+
+// Returns cached task metadata, unless the cache is missing, stale, belongs to
+// another endpoint, or has no task ARN.
+func readMetadataCache(path, endpoint string, ttl time.Duration) (*ecsmeta.Metadata, bool) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, false
+	}
+
+	var entry metadataCacheEntry
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	if entry.Endpoint != endpoint || entry.Metadata.EcsTaskARN == "" || time.Since(entry.FetchedAt) > ttl {
+		return nil, false
+	}
+
+	return &entry.Metadata, true
+}
+
+// Stores task metadata in the cache. Cache of another task (with different ARN)
+// is simply overwritten.
+func writeMetadataCache(path, endpoint string, metadata *ecsmeta.Metadata) error {
+	entry := metadataCacheEntry{Endpoint: endpoint, FetchedAt: time.Now(), Metadata: *metadata}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+// Registers `--metadata-cache` and related flags on the command.
+func addMetadataCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&metadataCache, "metadata-cache", false, "Cache ECS task metadata on disk and reuse it while fresh")
+	cmd.Flags().StringVar(&metadataCachePath, "metadata-cache-path", metadataCachePath, "ECS task metadata cache file")
+	cmd.Flags().DurationVar(&metadataCacheTTL, "metadata-cache-ttl", metadataCacheTTL, "How long cached ECS task metadata stays fresh")
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataCache(t *testing.T) {
+	metadata := &ecsmeta.Metadata{
+		AwsRegion:      "aws-region-1",
+		EcsClusterName: "cluster-name",
+		EcsTaskARN:     "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+		EcsTaskID:      "deadbeef",
+		EcsTaskLimits:  ecsmeta.TaskLimits{CPU: "0.25", Memory: "512"},
+	}
+
+	t.Run("reads back written metadata", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+
+		assert.Nil(t, writeMetadataCache(path, "http://169.254.170.2/v4/deadbeef", metadata))
+
+		cached, ok := readMetadataCache(path, "http://169.254.170.2/v4/deadbeef", time.Minute)
+
+		assert.True(t, ok, "expected cache hit")
+		assert.Equal(t, metadata, cached)
+	})
+
+	t.Run("misses when cache is stale", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+		data, _ := json.Marshal(metadataCacheEntry{
+			Endpoint:  "http://169.254.170.2/v4/deadbeef",
+			FetchedAt: time.Now().Add(-2 * time.Minute),
+			Metadata:  *metadata,
+		})
+
+		assert.Nil(t, os.WriteFile(path, data, 0o600))
+
+		_, ok := readMetadataCache(path, "http://169.254.170.2/v4/deadbeef", time.Minute)
+
+		assert.False(t, ok, "expected cache miss")
+	})
+
+	t.Run("misses when cache belongs to another endpoint", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+
+		assert.Nil(t, writeMetadataCache(path, "http://169.254.170.2/v4/deadbeef", metadata))
+
+		_, ok := readMetadataCache(path, "http://169.254.170.2/v4/cafebabe", time.Minute)
+
+		assert.False(t, ok, "expected cache miss")
+	})
+
+	t.Run("misses when cached metadata has no task ARN", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+
+		assert.Nil(t, writeMetadataCache(path, "http://169.254.170.2/v4/deadbeef", &ecsmeta.Metadata{}))
+
+		_, ok := readMetadataCache(path, "http://169.254.170.2/v4/deadbeef", time.Minute)
+
+		assert.False(t, ok, "expected cache miss")
+	})
+
+	t.Run("misses when cache is missing or malformed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+
+		_, ok := readMetadataCache(path, "http://169.254.170.2/v4/deadbeef", time.Minute)
+
+		assert.False(t, ok, "expected cache miss")
+
+		assert.Nil(t, os.WriteFile(path, []byte("wazzup"), 0o600))
+
+		_, ok = readMetadataCache(path, "http://169.254.170.2/v4/deadbeef", time.Minute)
+
+		assert.False(t, ok, "expected cache miss")
+	})
+}
+
+func TestGetEcsTaskMetadata_Cache(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/task" {
+			calls.Add(1)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Cluster": "cluster-name", "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef"}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	oldCache, oldPath, oldURI := metadataCache, metadataCachePath, metadataURI
+
+	t.Cleanup(func() {
+		metadataCache, metadataCachePath, metadataURI = oldCache, oldPath, oldURI
+	})
+
+	metadataCache, metadataCachePath, metadataURI = true, filepath.Join(t.TempDir(), "cache.json"), server.URL
+
+	first, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+	assert.Nil(t, err, "expected no error")
+
+	second, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, first, second)
+	assert.Equal(t, "deadbeef", second.EcsTaskID)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+var levelColors = map[string]string{
+	"DEBUG": "\x1b[90m", // bright black
+	"INFO":  "\x1b[32m", // green
+	"WARN":  "\x1b[33m", // yellow
+	"ERROR": "\x1b[31m", // red
+}
+
+const colorReset = "\x1b[0m"
+
+var textLevelPattern = regexp.MustCompile(`level=(DEBUG|INFO|WARN|ERROR)([+-]\d+)?`)
+
+// Colorizes level of log records written by text handler. Relies on the text
+// handler writing each record with a single Write call.
+type levelColorWriter struct {
+	w io.Writer
+}
+
+func (cw *levelColorWriter) Write(p []byte) (int, error) {
+	loc := textLevelPattern.FindSubmatchIndex(p)
+
+	if loc == nil {
+		return cw.w.Write(p)
+	}
+
+	level := string(p[loc[2]:loc[3]])
+	value := p[loc[0]+len("level=") : loc[1]]
+
+	var buf bytes.Buffer
+
+	buf.Write(p[:loc[0]])
+	buf.WriteString("level=" + levelColors[level])
+	buf.Write(value)
+	buf.WriteString(colorReset)
+	buf.Write(p[loc[1]:])
+
+	if _, err := cw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Returns whether log output to `w` should be colorized: only when it's a
+// terminal, and NO_COLOR environment variable is not set.
+//
+// See: https://no-color.org
+func shouldColorize(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
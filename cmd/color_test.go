@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelColorWriter(t *testing.T) {
+	t.Run("colorizes levels", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		logger := slog.New(slog.NewTextHandler(&levelColorWriter{w: &buf}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error")
+
+		assert.Contains(t, buf.String(), "level=\x1b[90mDEBUG\x1b[0m msg=debug")
+		assert.Contains(t, buf.String(), "level=\x1b[32mINFO\x1b[0m msg=info")
+		assert.Contains(t, buf.String(), "level=\x1b[33mWARN\x1b[0m msg=warn")
+		assert.Contains(t, buf.String(), "level=\x1b[31mERROR\x1b[0m msg=error")
+	})
+
+	t.Run("colorizes custom levels", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		n, err := (&levelColorWriter{w: &buf}).Write([]byte("level=WARN+2 msg=hello\n"))
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, len("level=WARN+2 msg=hello\n"), n)
+		assert.Equal(t, "level=\x1b[33mWARN+2\x1b[0m msg=hello\n", buf.String())
+	})
+
+	t.Run("passes through records without level", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		_, err := (&levelColorWriter{w: &buf}).Write([]byte("msg=hello\n"))
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "msg=hello\n", buf.String())
+	})
+}
+
+func TestShouldColorize(t *testing.T) {
+	t.Run("returns false for non-file writers", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+
+		assert.False(t, shouldColorize(&bytes.Buffer{}))
+	})
+
+	t.Run("returns false for regular files", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+
+		f, err := os.Create(filepath.Join(t.TempDir(), "log"))
+
+		assert.Nil(t, err, "expected no error")
+		t.Cleanup(func() { f.Close() })
+
+		assert.False(t, shouldColorize(f))
+	})
+
+	t.Run("returns false when NO_COLOR is set", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+
+		f, err := os.Open(os.DevNull)
+
+		assert.Nil(t, err, "expected no error")
+		t.Cleanup(func() { f.Close() })
+
+		assert.False(t, shouldColorize(f))
+	})
+
+	t.Run("returns true for character devices", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+
+		f, err := os.Open(os.DevNull)
+
+		assert.Nil(t, err, "expected no error")
+		t.Cleanup(func() { f.Close() })
+
+		assert.True(t, shouldColorize(f))
+	})
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// Shells completion scripts can be generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+var (
+	completionShell string
+	completionPath  string
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:       "completion [bash|zsh|fish]",
+	Short:     "Prints shell completion script",
+	Long:      `Prints completion script for the given shell, or the one detected from $SHELL.`,
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: completionShells,
+	RunE:      completionCmdRunE,
+}
+
+// completionInstallCmd represents the completion install command
+var completionInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Installs shell completion script",
+	Long: `Writes completion script for the shell detected from $SHELL (or given with
+--shell) to the location it loads completions from:
+
+  bash  $XDG_DATA_HOME/bash-completion/completions/fluent-bit-for-ecs
+  zsh   $XDG_DATA_HOME/zsh/site-functions/_fluent-bit-for-ecs (must be in $fpath)
+  fish  $XDG_CONFIG_HOME/fish/completions/fluent-bit-for-ecs.fish`,
+	Args: cobra.NoArgs,
+	RunE: completionInstallCmdRunE,
+}
+
+// Returns `shell` if given, or the base name of `$SHELL` otherwise, failing
+// if it's not one of the supported shells.
+func detectShell(shell string) (string, error) {
+	if shell == "" {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+
+	if !slices.Contains(completionShells, shell) {
+		return "", fmt.Errorf("unsupported shell %q (expected bash, zsh or fish, use --shell to choose one)", shell)
+	}
+
+	return shell, nil
+}
+
+// Returns XDG base directory given by `env` environment variable, falling back
+// to `fallback` relative to the user's home directory.
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, fallback), nil
+}
+
+// Returns path the `shell` loads completion script of the command from.
+func defaultCompletionPath(shell string) (string, error) {
+	name := rootCmd.Name()
+
+	switch shell {
+	case "bash":
+		dir, err := xdgDir("XDG_DATA_HOME", ".local/share")
+		return filepath.Join(dir, "bash-completion", "completions", name), err
+
+	case "zsh":
+		dir, err := xdgDir("XDG_DATA_HOME", ".local/share")
+		return filepath.Join(dir, "zsh", "site-functions", "_"+name), err
+
+	case "fish":
+		dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+		return filepath.Join(dir, "fish", "completions", name+".fish"), err
+
+	default:
+		return "", fmt.Errorf("unsupported shell %q", shell)
+	}
+}
+
+// Writes completion script of the `shell` to `w`.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(w, true)
+
+	case "zsh":
+		return rootCmd.GenZshCompletion(w)
+
+	case "fish":
+		return rootCmd.GenFishCompletion(w, true)
+
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+}
+
+// Writes completion script of the `shell` to `path`, creating its directory.
+func installCompletion(path, shell string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, func(w io.Writer) error { return writeCompletion(w, shell) }); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, 0o644)
+}
+
+func completionCmdRunE(cmd *cobra.Command, args []string) error {
+	var shell string
+
+	if len(args) > 0 {
+		shell = args[0]
+	}
+
+	shell, err := detectShell(shell)
+
+	if err != nil {
+		return err
+	}
+
+	return writeCompletion(cmd.OutOrStdout(), shell)
+}
+
+func completionInstallCmdRunE(cmd *cobra.Command, args []string) error {
+	shell, err := detectShell(completionShell)
+
+	if err != nil {
+		return err
+	}
+
+	path := completionPath
+
+	if path == "" {
+		if path, err = defaultCompletionPath(shell); err != nil {
+			return err
+		}
+	}
+
+	if err := installCompletion(path, shell); err != nil {
+		slog.Error("Can't install completion script", "shell", shell, "path", path, "error", err)
+		return err
+	}
+
+	slog.Info("Installed completion script, restart the shell to use it", "shell", shell, "path", path)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	completionCmd.AddCommand(completionInstallCmd)
+
+	completionInstallCmd.Flags().StringVar(&completionShell, "shell", "", "Shell to install completion for: bash, zsh or fish (detected from $SHELL by default)")
+	completionInstallCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions(completionShells, cobra.ShellCompDirectiveNoFileComp))
+	completionInstallCmd.Flags().StringVar(&completionPath, "path", "", "Write completion script to the given file instead of the shell's default location")
+}
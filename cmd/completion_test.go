@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectShell(t *testing.T) {
+	t.Run("detects shell from $SHELL", func(t *testing.T) {
+		t.Setenv("SHELL", "/usr/bin/zsh")
+
+		shell, err := detectShell("")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "zsh", shell)
+	})
+
+	t.Run("prefers explicitly given shell", func(t *testing.T) {
+		t.Setenv("SHELL", "/usr/bin/zsh")
+
+		shell, err := detectShell("fish")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "fish", shell)
+	})
+
+	t.Run("fails on unsupported shell", func(t *testing.T) {
+		t.Setenv("SHELL", "/bin/ash")
+
+		_, err := detectShell("")
+
+		assert.ErrorContains(t, err, `unsupported shell "ash"`)
+	})
+
+	t.Run("fails when $SHELL is not set", func(t *testing.T) {
+		t.Setenv("SHELL", "")
+
+		_, err := detectShell("")
+
+		assert.NotNil(t, err, "expected an error")
+	})
+}
+
+func TestDefaultCompletionPath(t *testing.T) {
+	t.Setenv("HOME", "/home/fluent-bit")
+
+	t.Run("falls back to home directory", func(t *testing.T) {
+		t.Setenv("XDG_DATA_HOME", "")
+		t.Setenv("XDG_CONFIG_HOME", "")
+
+		for shell, expected := range map[string]string{
+			"bash": "/home/fluent-bit/.local/share/bash-completion/completions/fluent-bit-for-ecs",
+			"zsh":  "/home/fluent-bit/.local/share/zsh/site-functions/_fluent-bit-for-ecs",
+			"fish": "/home/fluent-bit/.config/fish/completions/fluent-bit-for-ecs.fish",
+		} {
+			path, err := defaultCompletionPath(shell)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, expected, path)
+		}
+	})
+
+	t.Run("respects XDG base directories", func(t *testing.T) {
+		t.Setenv("XDG_DATA_HOME", "/xdg/data")
+		t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+
+		for shell, expected := range map[string]string{
+			"bash": "/xdg/data/bash-completion/completions/fluent-bit-for-ecs",
+			"zsh":  "/xdg/data/zsh/site-functions/_fluent-bit-for-ecs",
+			"fish": "/xdg/config/fish/completions/fluent-bit-for-ecs.fish",
+		} {
+			path, err := defaultCompletionPath(shell)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, expected, path)
+		}
+	})
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			assert.Nil(t, writeCompletion(&buf, shell), "expected no error")
+			assert.Contains(t, buf.String(), "fluent-bit-for-ecs")
+		})
+	}
+
+	t.Run("unsupported shell", func(t *testing.T) {
+		assert.NotNil(t, writeCompletion(&bytes.Buffer{}, "ash"), "expected an error")
+	})
+}
+
+func TestInstallCompletion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bash-completion", "completions", "fluent-bit-for-ecs")
+
+	assert.Nil(t, installCompletion(path, "bash"), "expected no error")
+
+	info, err := os.Stat(path)
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	content, _ := os.ReadFile(path)
+
+	assert.Contains(t, string(content), "bash completion V2 for fluent-bit-for-ecs")
+}
@@ -0,0 +1,149 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Resolves user and group (names or numeric IDs) into process credentials.
+// When group is not given, primary group of the user is used. Supplementary
+// groups are populated only for users known to the system.
+func resolveCredential(userSpec, groupSpec string) (*syscall.Credential, error) {
+	cred := &syscall.Credential{}
+
+	u, err := lookupUser(userSpec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q of user %q: %w", u.Uid, userSpec, err)
+	}
+
+	cred.Uid = uint32(uid)
+
+	gidSpec := u.Gid
+
+	if groupSpec != "" {
+		g, err := lookupGroup(groupSpec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		gidSpec = g.Gid
+	}
+
+	gid, err := strconv.ParseUint(gidSpec, 10, 32)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q: %w", gidSpec, err)
+	}
+
+	cred.Gid = uint32(gid)
+	cred.Groups = []uint32{cred.Gid}
+
+	if u.Username != "" && groupSpec == "" {
+		if groupIds, err := u.GroupIds(); err == nil {
+			cred.Groups = cred.Groups[:0]
+
+			for _, id := range groupIds {
+				if gid, err := strconv.ParseUint(id, 10, 32); err == nil {
+					cred.Groups = append(cred.Groups, uint32(gid))
+				}
+			}
+		}
+	}
+
+	return cred, nil
+}
+
+// Looks up user by name, falling back to numeric uid. Unknown numeric uids
+// are allowed and get the group with the same id as their primary group.
+func lookupUser(spec string) (*user.User, error) {
+	if u, err := user.Lookup(spec); err == nil {
+		return u, nil
+	}
+
+	if u, err := user.LookupId(spec); err == nil {
+		return u, nil
+	}
+
+	if _, err := strconv.ParseUint(spec, 10, 32); err == nil {
+		return &user.User{Uid: spec, Gid: spec}, nil
+	}
+
+	return nil, fmt.Errorf("unknown user %q", spec)
+}
+
+// Looks up group by name, falling back to numeric gid.
+func lookupGroup(spec string) (*user.Group, error) {
+	if g, err := user.LookupGroup(spec); err == nil {
+		return g, nil
+	}
+
+	if _, err := strconv.ParseUint(spec, 10, 32); err == nil {
+		return &user.Group{Gid: spec}, nil
+	}
+
+	return nil, fmt.Errorf("unknown group %q", spec)
+}
+
+// Switches credentials of the current process. Groups are changed first, as
+// once uid is changed we no longer have privileges to do so.
+func setCredential(cred *syscall.Credential) error {
+	groups := make([]int, 0, len(cred.Groups))
+
+	for _, gid := range cred.Groups {
+		groups = append(groups, int(gid))
+	}
+
+	if err := syscall.Setgroups(groups); err != nil {
+		return credentialError("supplementary groups", err)
+	}
+
+	if err := syscall.Setgid(int(cred.Gid)); err != nil {
+		return credentialError("gid "+strconv.Itoa(int(cred.Gid)), err)
+	}
+
+	if err := syscall.Setuid(int(cred.Uid)); err != nil {
+		return credentialError("uid "+strconv.Itoa(int(cred.Uid)), err)
+	}
+
+	return nil
+}
+
+func credentialError(what string, err error) error {
+	if errors.Is(err, syscall.EPERM) {
+		return fmt.Errorf("can't switch %s: insufficient privileges (are you root?): %w", what, err)
+	}
+
+	return fmt.Errorf("can't switch %s: %w", what, err)
+}
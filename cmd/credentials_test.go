@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveCredential(t *testing.T) {
+	t.Run("resolves user by name", func(t *testing.T) {
+		cred, err := resolveCredential("root", "")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, uint32(0), cred.Uid)
+		assert.Equal(t, uint32(0), cred.Gid)
+		assert.Contains(t, cred.Groups, uint32(0))
+	})
+
+	t.Run("resolves unknown numeric user", func(t *testing.T) {
+		cred, err := resolveCredential("31337", "")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, uint32(31337), cred.Uid)
+		assert.Equal(t, uint32(31337), cred.Gid)
+		assert.Equal(t, []uint32{31337}, cred.Groups)
+	})
+
+	t.Run("resolves explicit group", func(t *testing.T) {
+		cred, err := resolveCredential("31337", "42")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, uint32(31337), cred.Uid)
+		assert.Equal(t, uint32(42), cred.Gid)
+		assert.Equal(t, []uint32{42}, cred.Groups)
+	})
+
+	t.Run("returns error on unknown user", func(t *testing.T) {
+		cred, err := resolveCredential("he-is-not-the-messiah", "")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, cred)
+	})
+
+	t.Run("returns error on unknown group", func(t *testing.T) {
+		cred, err := resolveCredential("root", "he-is-a-very-naughty-boy")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, cred)
+	})
+}
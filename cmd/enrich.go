@@ -0,0 +1,154 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/spf13/cobra"
+)
+
+var (
+	enrichService           bool
+	enrichContainerInstance bool
+)
+
+// Subset of ECS API used to enrich task metadata.
+type ecsDescriber interface {
+	DescribeServicesWithContext(aws.Context, *ecs.DescribeServicesInput, ...request.Option) (*ecs.DescribeServicesOutput, error)
+	DescribeContainerInstancesWithContext(aws.Context, *ecs.DescribeContainerInstancesInput, ...request.Option) (*ecs.DescribeContainerInstancesOutput, error)
+}
+
+// Returns ECS API client for the given region. Credentials are resolved with
+// the default chain of AWS SDK, thus in ECS those of the task role are used.
+var newECSClient = func(region string, client *http.Client) (ecsDescriber, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region).WithHTTPClient(client))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ecs.New(sess), nil
+}
+
+// Returns desired count of the ECS service the task was launched by.
+func fetchServiceDesiredCount(ctx context.Context, api ecsDescriber, metadata *ecsmeta.Metadata) (string, error) {
+	out, err := api.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cmp.Or(metadata.EcsClusterARN, metadata.EcsClusterName)),
+		Services: []*string{aws.String(metadata.EcsServiceName)},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(out.Failures) > 0 {
+		return "", fmt.Errorf("%s: %s", aws.StringValue(out.Failures[0].Arn), aws.StringValue(out.Failures[0].Reason))
+	}
+
+	if len(out.Services) == 0 || out.Services[0].DesiredCount == nil {
+		return "", errors.New("no desired count in ECS API response")
+	}
+
+	return strconv.FormatInt(*out.Services[0].DesiredCount, 10), nil
+}
+
+// Sets desired count of the service the task was launched by, retrieved from
+// ECS API. Requires `ecs:DescribeServices` permission, and leaves the count
+// empty (logging a warning) if it can't be retrieved for any reason.
+func enrichServiceMetadata(ctx context.Context, client *http.Client, metadata *ecsmeta.Metadata) {
+	if metadata.EcsServiceName == "" {
+		slog.Debug("Task was not launched by ECS service, skipping service enrichment")
+		return
+	}
+
+	api, err := newECSClient(metadata.AwsRegion, client)
+
+	if err == nil {
+		metadata.EcsServiceDesiredCount, err = fetchServiceDesiredCount(ctx, api, metadata)
+	}
+
+	if err != nil {
+		slog.Warn("Can't retrieve ECS service desired count, skipping", "service", metadata.EcsServiceName, "error", err)
+	}
+}
+
+// Returns ID of the EC2 instance the task runs on, given its ECS container
+// instance ARN.
+func fetchEc2InstanceID(ctx context.Context, api ecsDescriber, metadata *ecsmeta.Metadata) (string, error) {
+	out, err := api.DescribeContainerInstancesWithContext(ctx, &ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(cmp.Or(metadata.EcsClusterARN, metadata.EcsClusterName)),
+		ContainerInstances: []*string{aws.String(metadata.EcsContainerInstanceARN)},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(out.Failures) > 0 {
+		return "", fmt.Errorf("%s: %s", aws.StringValue(out.Failures[0].Arn), aws.StringValue(out.Failures[0].Reason))
+	}
+
+	if len(out.ContainerInstances) == 0 || aws.StringValue(out.ContainerInstances[0].Ec2InstanceId) == "" {
+		return "", errors.New("no EC2 instance ID in ECS API response")
+	}
+
+	return *out.ContainerInstances[0].Ec2InstanceId, nil
+}
+
+// Sets ID of the EC2 instance the task runs on, retrieved from ECS API.
+// Requires `ecs:DescribeContainerInstances` permission, and leaves the ID
+// empty (logging a warning) if it can't be retrieved for any reason.
+func enrichContainerInstanceMetadata(ctx context.Context, client *http.Client, metadata *ecsmeta.Metadata) {
+	if metadata.EcsContainerInstanceARN == "" {
+		slog.Debug("Task doesn't run on ECS container instance, skipping container instance enrichment")
+		return
+	}
+
+	api, err := newECSClient(metadata.AwsRegion, client)
+
+	if err == nil {
+		metadata.Ec2InstanceID, err = fetchEc2InstanceID(ctx, api, metadata)
+	}
+
+	if err != nil {
+		slog.Warn("Can't retrieve EC2 instance ID, skipping", "container_instance", metadata.EcsContainerInstanceARN, "error", err)
+	}
+}
+
+// Registers `--enrich-service` and `--enrich-container-instance` flags on the
+// command.
+func addEnrichFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&enrichService, "enrich-service", false, "Retrieve ECS_SERVICE_DESIRED_COUNT from ECS API with the task role (requires ecs:DescribeServices)")
+	cmd.Flags().BoolVar(&enrichContainerInstance, "enrich-container-instance", false, "Retrieve EC2_INSTANCE_ID from ECS API with the task role (requires ecs:DescribeContainerInstances, EC2 launch type only)")
+}
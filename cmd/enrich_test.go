@@ -0,0 +1,222 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeECSClient struct {
+	input  *ecs.DescribeServicesInput
+	output *ecs.DescribeServicesOutput
+	err    error
+
+	instancesInput  *ecs.DescribeContainerInstancesInput
+	instancesOutput *ecs.DescribeContainerInstancesOutput
+}
+
+func (c *fakeECSClient) DescribeServicesWithContext(_ aws.Context, input *ecs.DescribeServicesInput, _ ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	c.input = input
+
+	return c.output, c.err
+}
+
+func (c *fakeECSClient) DescribeContainerInstancesWithContext(_ aws.Context, input *ecs.DescribeContainerInstancesInput, _ ...request.Option) (*ecs.DescribeContainerInstancesOutput, error) {
+	c.instancesInput = input
+
+	return c.instancesOutput, c.err
+}
+
+func TestFetchServiceDesiredCount(t *testing.T) {
+	metadata := &ecsmeta.Metadata{
+		EcsClusterName: "cluster-name",
+		EcsClusterARN:  "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+		EcsServiceName: "service-name",
+	}
+
+	t.Run("returns desired count of the service", func(t *testing.T) {
+		api := &fakeECSClient{output: &ecs.DescribeServicesOutput{Services: []*ecs.Service{{DesiredCount: aws.Int64(3)}}}}
+
+		count, err := fetchServiceDesiredCount(context.Background(), api, metadata)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "3", count)
+		assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name", aws.StringValue(api.input.Cluster))
+		assert.Equal(t, []string{"service-name"}, aws.StringValueSlice(api.input.Services))
+	})
+
+	t.Run("falls back to cluster name", func(t *testing.T) {
+		api := &fakeECSClient{output: &ecs.DescribeServicesOutput{Services: []*ecs.Service{{DesiredCount: aws.Int64(0)}}}}
+
+		count, err := fetchServiceDesiredCount(context.Background(), api, &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsServiceName: "service-name"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "0", count)
+		assert.Equal(t, "cluster-name", aws.StringValue(api.input.Cluster))
+	})
+
+	t.Run("fails on API failures", func(t *testing.T) {
+		api := &fakeECSClient{output: &ecs.DescribeServicesOutput{Failures: []*ecs.Failure{{Arn: aws.String("service-name"), Reason: aws.String("MISSING")}}}}
+
+		_, err := fetchServiceDesiredCount(context.Background(), api, metadata)
+
+		assert.ErrorContains(t, err, "MISSING")
+	})
+
+	t.Run("fails on API errors", func(t *testing.T) {
+		api := &fakeECSClient{err: errors.New("AccessDeniedException")}
+
+		_, err := fetchServiceDesiredCount(context.Background(), api, metadata)
+
+		assert.ErrorContains(t, err, "AccessDeniedException")
+	})
+}
+
+func TestEnrichServiceMetadata(t *testing.T) {
+	oldNewECSClient := newECSClient
+
+	t.Cleanup(func() { newECSClient = oldNewECSClient })
+
+	api := &fakeECSClient{}
+
+	newECSClient = func(string, *http.Client) (ecsDescriber, error) { return api, nil }
+
+	t.Run("sets desired count of the service", func(t *testing.T) {
+		api.output, api.err = &ecs.DescribeServicesOutput{Services: []*ecs.Service{{DesiredCount: aws.Int64(3)}}}, nil
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsServiceName: "service-name"}
+
+		enrichServiceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Equal(t, "3", metadata.EcsServiceDesiredCount)
+	})
+
+	t.Run("fails soft", func(t *testing.T) {
+		api.output, api.err = nil, errors.New("AccessDeniedException")
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsServiceName: "service-name"}
+
+		enrichServiceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Equal(t, "", metadata.EcsServiceDesiredCount)
+	})
+
+	t.Run("skips standalone tasks", func(t *testing.T) {
+		api.input = nil
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name"}
+
+		enrichServiceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Nil(t, api.input, "expected no ECS API calls")
+		assert.Equal(t, "", metadata.EcsServiceDesiredCount)
+	})
+}
+
+func TestFetchEc2InstanceID(t *testing.T) {
+	metadata := &ecsmeta.Metadata{
+		EcsClusterName:          "cluster-name",
+		EcsClusterARN:           "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+		EcsContainerInstanceARN: "arn:aws:ecs:aws-region-1:123456789123:container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1",
+	}
+
+	t.Run("returns EC2 instance ID of the container instance", func(t *testing.T) {
+		api := &fakeECSClient{instancesOutput: &ecs.DescribeContainerInstancesOutput{ContainerInstances: []*ecs.ContainerInstance{{Ec2InstanceId: aws.String("i-0123456789abcdef0")}}}}
+
+		id, err := fetchEc2InstanceID(context.Background(), api, metadata)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "i-0123456789abcdef0", id)
+		assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name", aws.StringValue(api.instancesInput.Cluster))
+		assert.Equal(t, []string{metadata.EcsContainerInstanceARN}, aws.StringValueSlice(api.instancesInput.ContainerInstances))
+	})
+
+	t.Run("fails on API failures", func(t *testing.T) {
+		api := &fakeECSClient{instancesOutput: &ecs.DescribeContainerInstancesOutput{Failures: []*ecs.Failure{{Arn: aws.String(metadata.EcsContainerInstanceARN), Reason: aws.String("MISSING")}}}}
+
+		_, err := fetchEc2InstanceID(context.Background(), api, metadata)
+
+		assert.ErrorContains(t, err, "MISSING")
+	})
+
+	t.Run("fails when response has no EC2 instance ID", func(t *testing.T) {
+		api := &fakeECSClient{instancesOutput: &ecs.DescribeContainerInstancesOutput{ContainerInstances: []*ecs.ContainerInstance{{}}}}
+
+		_, err := fetchEc2InstanceID(context.Background(), api, metadata)
+
+		assert.ErrorContains(t, err, "no EC2 instance ID")
+	})
+
+	t.Run("fails on API errors", func(t *testing.T) {
+		api := &fakeECSClient{err: errors.New("AccessDeniedException")}
+
+		_, err := fetchEc2InstanceID(context.Background(), api, metadata)
+
+		assert.ErrorContains(t, err, "AccessDeniedException")
+	})
+}
+
+func TestEnrichContainerInstanceMetadata(t *testing.T) {
+	oldNewECSClient := newECSClient
+
+	t.Cleanup(func() { newECSClient = oldNewECSClient })
+
+	api := &fakeECSClient{}
+
+	newECSClient = func(string, *http.Client) (ecsDescriber, error) { return api, nil }
+
+	containerInstanceARN := "arn:aws:ecs:aws-region-1:123456789123:container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1"
+
+	t.Run("sets EC2 instance ID", func(t *testing.T) {
+		api.instancesOutput, api.err = &ecs.DescribeContainerInstancesOutput{ContainerInstances: []*ecs.ContainerInstance{{Ec2InstanceId: aws.String("i-0123456789abcdef0")}}}, nil
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsContainerInstanceARN: containerInstanceARN}
+
+		enrichContainerInstanceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Equal(t, "i-0123456789abcdef0", metadata.Ec2InstanceID)
+	})
+
+	t.Run("fails soft", func(t *testing.T) {
+		api.instancesOutput, api.err = nil, errors.New("AccessDeniedException")
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsContainerInstanceARN: containerInstanceARN}
+
+		enrichContainerInstanceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Equal(t, "", metadata.Ec2InstanceID)
+	})
+
+	t.Run("skips tasks without container instance", func(t *testing.T) {
+		api.instancesInput = nil
+		metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsLaunchType: "FARGATE"}
+
+		enrichContainerInstanceMetadata(context.Background(), newHTTPClient(), metadata)
+
+		assert.Nil(t, api.instancesInput, "expected no ECS API calls")
+		assert.Equal(t, "", metadata.Ec2InstanceID)
+	})
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var envFormat = "sh"
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Prints ECS task metadata as environment variables",
+	Long: `Prints ECS task metadata as environment variables.
+
+Useful for entrypoints that can't replace their process, e.g.:
+
+  eval "$(fluent-bit-for-ecs env)"`,
+	Args: cobra.NoArgs,
+	RunE: envCmdRunE,
+}
+
+// Values consisting of these characters only are safe to use in POSIX shells
+// without quoting.
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]*$`)
+
+// Quotes string for POSIX shells using single quotes, escaping embedded ones.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Quotes string for POSIX shells, unless it's safe to use as is.
+func shellEscape(s string) string {
+	if shellSafePattern.MatchString(s) {
+		return s
+	}
+
+	return shellQuote(s)
+}
+
+// Quotes string as a double-quoted dotenv value. Characters that shells expand
+// within double quotes are escaped as well, so that the file can be sourced.
+func dotenvQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`, "`", "\\`").Replace(s) + `"`
+}
+
+// Logs a warning for each variable whose value is not safe to use in POSIX
+// shells as is, and needs escaping. ECS task metadata values never do, unless
+// something is off.
+func warnUnsafeValues(environ []string) {
+	for _, v := range environ {
+		key, value, _ := strings.Cut(v, "=")
+
+		if !shellSafePattern.MatchString(value) {
+			slog.Warn("Escaping environment variable value with shell special characters", "key", key)
+		}
+	}
+}
+
+func writeEnviron(w io.Writer, format string, environ []string) error {
+	switch format {
+	case "sh", "dotenv":
+		warnUnsafeValues(environ)
+	}
+
+	switch format {
+	case "sh":
+		for _, v := range environ {
+			key, value, _ := strings.Cut(v, "=")
+
+			if _, err := fmt.Fprintf(w, "export %s=%s\n", key, shellQuote(value)); err != nil {
+				return err
+			}
+		}
+
+	case "dotenv":
+		for _, v := range environ {
+			key, value, _ := strings.Cut(v, "=")
+
+			if _, err := fmt.Fprintf(w, "%s=%s\n", key, dotenvQuote(value)); err != nil {
+				return err
+			}
+		}
+
+	case "json":
+		object := make(map[string]string, len(environ))
+
+		for _, v := range environ {
+			key, value, _ := strings.Cut(v, "=")
+			object[key] = value
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(object)
+
+	default:
+		return fmt.Errorf("unsupported format %q (expected sh, dotenv or json)", format)
+	}
+
+	return nil
+}
+
+func envCmdRunE(cmd *cobra.Command, args []string) error {
+	if err := validatePrefer(envPrefer); err != nil {
+		return err
+	}
+
+	if err := validateExclude(envExclude); err != nil {
+		return err
+	}
+
+	metadata, err := getEcsTaskMetadata(cmd.Context(), newHTTPClient())
+
+	if err != nil {
+		slog.Error("Can't retrieve ECS task metadata", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	return writeEnviron(cmd.OutOrStdout(), envFormat, managedEnviron(metadata))
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	addMetadataEndpointFlags(envCmd)
+	addMetadataCacheFlags(envCmd)
+	addPreferFlag(envCmd)
+	addExcludeFlag(envCmd)
+	addTemplateFlags(envCmd)
+	addEnrichFlags(envCmd)
+	addClusterArnStyleFlag(envCmd)
+	envCmd.Flags().StringVar(&envFormat, "format", envFormat, "Output format: sh, dotenv or json")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	t.Run("wraps value in single quotes", func(t *testing.T) {
+		assert.Equal(t, `''`, shellQuote(""))
+		assert.Equal(t, `'foo bar'`, shellQuote("foo bar"))
+		assert.Equal(t, `'$HOME "x"'`, shellQuote(`$HOME "x"`))
+		assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	})
+}
+
+func TestShellEscape(t *testing.T) {
+	t.Run("returns safe values as is", func(t *testing.T) {
+		assert.Equal(t, "", shellEscape(""))
+		assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef", shellEscape("arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef"))
+		assert.Equal(t, "fluent/fluent-bit@sha256:deadbeef", shellEscape("fluent/fluent-bit@sha256:deadbeef"))
+	})
+
+	t.Run("quotes values with spaces, quotes and $", func(t *testing.T) {
+		assert.Equal(t, `'foo bar'`, shellEscape("foo bar"))
+		assert.Equal(t, `'it'\''s "x"'`, shellEscape(`it's "x"`))
+		assert.Equal(t, `'$(reboot)'`, shellEscape("$(reboot)"))
+		assert.Equal(t, "'a\nb'", shellEscape("a\nb"))
+	})
+}
+
+func TestDotenvQuote(t *testing.T) {
+	t.Run("escapes characters shells expand within double quotes", func(t *testing.T) {
+		assert.Equal(t, `"foo bar"`, dotenvQuote("foo bar"))
+		assert.Equal(t, `"\$HOME \`+"`"+`reboot\`+"`"+`"`, dotenvQuote("$HOME `reboot`"))
+		assert.Equal(t, `"a\nb\rc \\ \"x\""`, dotenvQuote("a\nb\rc \\ \"x\""))
+	})
+}
+
+func TestWriteEnviron(t *testing.T) {
+	environ := []string{"ECS_TASK_ID=deadbeef", "AWS_REGION=aws-region-1", `ECS_SERVICE_NAME=it's "x"`}
+
+	t.Run("sh", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeEnviron(&buf, "sh", environ), "expected no error")
+		assert.Equal(t, "export ECS_TASK_ID='deadbeef'\nexport AWS_REGION='aws-region-1'\nexport ECS_SERVICE_NAME='it'\\''s \"x\"'\n", buf.String())
+	})
+
+	t.Run("dotenv", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeEnviron(&buf, "dotenv", environ), "expected no error")
+		assert.Equal(t, "ECS_TASK_ID=\"deadbeef\"\nAWS_REGION=\"aws-region-1\"\nECS_SERVICE_NAME=\"it's \\\"x\\\"\"\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeEnviron(&buf, "json", environ), "expected no error")
+		assert.JSONEq(t, `{"AWS_REGION":"aws-region-1","ECS_SERVICE_NAME":"it's \"x\"","ECS_TASK_ID":"deadbeef"}`, buf.String())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NotNil(t, writeEnviron(&buf, "yaml", environ), "expected an error")
+		assert.Empty(t, buf.String())
+	})
+}
@@ -22,37 +22,108 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
 )
 
+var (
+	execDryRun            bool
+	execFormat            = "env"
+	envPrefix             string
+	execStrict            bool
+	execSupervise         bool
+	execPrintCommand      bool
+	execSkipLookPath      bool
+	execNoMetadata        bool
+	execStartupTimeout    time.Duration
+	execEnvMap            []string
+	execEnvMapKeep        bool
+	execStopGracePeriod   time.Duration
+	execStopTimeout       time.Duration
+	execForwardSignals    = forwardedSignals
+	execShutdownSignal    = "TERM"
+	execChdir             string
+	execArgsFile          string
+	execShell             bool
+	metadataURI           string
+	metadataFile          string
+	logGroupTemplate      = ecsmeta.DefaultLogGroupTemplate
+	deploymentKeyTemplate = ecsmeta.DefaultDeploymentKeyTemplate
+	clusterArnStyle       = "short"
+	metadataHeaders       []string
+	metadataTaskPath      = ecsmeta.DefaultTaskPath
+	metadataStrictSchema  bool
+	execEnvFile           string
+	execOutput            string
+	execKeepEnv           bool
+	execEnvAllowlist      []string
+	envPrefer             = "metadata"
+	envExclude            []string
+	execSet               []string
+	execForce             bool
+	execUser              string
+	execGroup             string
+	metadataRetries       = 3
+	metadataRetryDelay    = 100 * time.Millisecond
+	metadataRetryJitter   = true
+	metadataMaxElapsed    = 10 * time.Second
+	metadataTimeout       = 2 * time.Second
+)
+
+// Returned when exec is interrupted by a signal before running the command.
+var errExecInterrupted = errors.New("interrupted before executing command")
+
 // execCmd represents the exec command
 var execCmd = &cobra.Command{
-	Use:                   "exec command [args...]",
-	Short:                 "Executes a command with ECS task metadata loaded into the environment",
-	Args:                  cobra.MinimumNArgs(1),
+	Use:   "exec command [args...]",
+	Short: "Executes a command with ECS task metadata loaded into the environment",
+	Long: `Executes a command with ECS task metadata loaded into the environment.
+
+Flags are parsed up to the command only: everything after it is passed to the
+command intact, so "exec --dry-run cmd --dry-run" gives --dry-run to cmd. Use
+"--" to separate flags from the command explicitly.
+
+With --args-file, arguments are read from the file, one per line (empty lines
+and lines starting with # are skipped), and appended after the ones given on
+the command line. If no command is given on the command line, the first line
+of the file is the command.
+
+With --shell, arguments are joined with spaces and run as "/bin/sh -c <args>",
+so pipes, redirections and variables work. Arguments are joined verbatim, thus
+quoting is up to the shell: pass the snippet as a single quoted argument, e.g.
+exec --shell 'fluent-bit -c "$CONFIG" | tee /tmp/log', to keep the outer shell
+from interpreting it. Signals are delivered to sh, not to the commands it runs,
+unless the snippet starts with exec.`,
+	Args:                  execCmdArgs,
 	DisableFlagsInUseLine: true,
 	RunE:                  execCmdRunE,
 }
 
-// See: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-metadata-endpoint-v4-response.html
-type ecsTaskMetadata struct {
-	AwsRegion       string
-	EcsClusterName  string `json:"Cluster"`     // ECS Cluster Name
-	EcsServiceName  string `json:"ServiceName"` // ECS Service Name
-	EcsTaskFamily   string `json:"Family"`      // ECS Task Family
-	EcsTaskRevision string `json:"Revision"`    // ECS Task Revision
-	EcsTaskARN      string `json:"TaskARN"`     // ECS Task ARN
-	EcsTaskID       string
+// Requires command to be given, unless it comes from `--args-file`.
+func execCmdArgs(cmd *cobra.Command, args []string) error {
+	if execArgsFile != "" {
+		return nil
+	}
+
+	return cobra.MinimumNArgs(1)(cmd, args)
 }
 
 // Returns the first non-empty string from the provided arguments.
@@ -86,116 +157,957 @@ func stringStartsWith(s string, prefixes ...string) bool {
 	return false
 }
 
-func lastArnPart(arn arn.ARN) string {
-	parts := strings.Split(arn.Resource, "/")
-	return parts[len(parts)-1]
-}
-
+// Returns current environment without managed variables, both prefixed with
+// `envPrefix` and unprefixed.
 func cleanEnviron() []string {
+	prefixes := make([]string, 0, 2*len(ecsmeta.EnvKeys))
+
+	for _, key := range ecsmeta.EnvKeys {
+		prefixes = append(prefixes, key+"=")
+
+		if envPrefix != "" {
+			prefixes = append(prefixes, envPrefix+key+"=")
+		}
+	}
+
 	return slices.DeleteFunc(os.Environ(), func(v string) bool {
-		return stringStartsWith(v,
-			"AWS_REGION=",
-			"ECS_CLUSTER_NAME=",
-			"ECS_SERVICE_NAME=",
-			"ECS_TASK_FAMILY=",
-			"ECS_TASK_REVISION=",
-			"ECS_TASK_ARN=",
-			"ECS_TASK_ID=",
-		)
+		return stringStartsWith(v, prefixes...)
 	})
 }
 
-func (m *ecsTaskMetadata) Environ() []string {
-	metadataEnviron := []string{
-		"AWS_REGION=" + firstNonEmpty(os.Getenv("AWS_REGION"), m.AwsRegion),
-		"ECS_CLUSTER_NAME=" + firstNonEmpty(os.Getenv("ECS_CLUSTER_NAME"), m.EcsClusterName),
-		"ECS_SERVICE_NAME=" + firstNonEmpty(os.Getenv("ECS_SERVICE_NAME"), m.EcsServiceName),
-		"ECS_TASK_FAMILY=" + firstNonEmpty(m.EcsTaskFamily, os.Getenv("ECS_TASK_FAMILY")),
-		"ECS_TASK_REVISION=" + firstNonEmpty(m.EcsTaskRevision, os.Getenv("ECS_TASK_REVISION")),
-		"ECS_TASK_ARN=" + firstNonEmpty(m.EcsTaskARN, os.Getenv("ECS_TASK_ARN")),
-		"ECS_TASK_ID=" + firstNonEmpty(m.EcsTaskID, os.Getenv("ECS_TASK_ID")),
+// Returns `KEY=VALUE` pairs of the managed environment variables only,
+// resolved against the current environment. Keys are prefixed with
+// `envPrefix`, if any.
+//
+// Metadata values take precedence over existing environment variables, which
+// are used as a fallback only. With `--prefer=env` it's the other way around.
+//
+// Variables given with `--exclude` are omitted, and ones given with `--env-map`
+// are renamed. Returns nil without metadata.
+func managedEnviron(m *ecsmeta.Metadata) []string {
+	if m == nil {
+		return nil
+	}
+
+	environ := slices.DeleteFunc(m.Environ(), func(v string) bool {
+		key, _, _ := strings.Cut(v, "=")
+		return slices.Contains(envExclude, key)
+	})
+
+	for i, v := range environ {
+		key, value, _ := strings.Cut(v, "=")
+		value, _ = resolveManagedValue(key, value)
+
+		environ[i] = envPrefix + key + "=" + value
 	}
 
-	slog.Debug("Setting environment variables", "metadata", metadataEnviron)
+	return mapEnviron(environ, execEnvMap, execEnvMapKeep)
+}
+
+// Managed variables whose values are derived from other metadata values,
+// rather than given by the endpoint as is.
+var derivedEnvKeys = []string{
+	"AWS_REGION",
+	"AWS_ACCOUNT_ID",
+	"AWS_PARTITION",
+	"ECS_CLUSTER_ARN",
+	"ECS_TASK_ID",
+	"ECS_TASK_DEFINITION_ARN",
+	"ECS_TASK_IP",
+	"ECS_LOG_GROUP",
+	"ECS_DEPLOYMENT_KEY",
+}
 
-	return append(cleanEnviron(), metadataEnviron...)
+// Managed variables whose values are retrieved from ECS API, see
+// `--enrich-service` and `--enrich-container-instance`.
+var apiEnvKeys = []string{
+	"ECS_SERVICE_DESIRED_COUNT",
+	"EC2_INSTANCE_ID",
 }
 
-func getEcsTaskMetadata() (*ecsTaskMetadata, error) {
-	metadata := &ecsTaskMetadata{}
-	ecsTaskMetadataEndpoint := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+// Resolves value of the managed variable `key` against the current
+// environment, see managedEnviron. Returns the value along with its source:
+// `metadata`, `derived` (from metadata), `api` (ECS API), `env` or `default`
+// (when empty).
+func resolveManagedValue(key, value string) (string, string) {
+	existing := os.Getenv(envPrefix + key)
+	source := "metadata"
 
-	if ecsTaskMetadataEndpoint == "" {
-		slog.Warn("ECS_CONTAINER_METADATA_URI_V4 environment variable is not set, skipping ECS metadata retrieval")
-		return metadata, nil
+	if slices.Contains(derivedEnvKeys, key) {
+		source = "derived"
+	}
+
+	if slices.Contains(apiEnvKeys, key) {
+		source = "api"
+	}
+
+	switch {
+	case envPrefer == "env" && existing != "":
+		return existing, "env"
+	case value != "":
+		return value, source
+	case existing != "":
+		return existing, "env"
+	default:
+		return "", "default"
+	}
+}
+
+// Returns `environ` with managed variables renamed according to `SRC=DST`
+// `pairs`. Originals are kept along with renamed ones when `keep` is true.
+func mapEnviron(environ, pairs []string, keep bool) []string {
+	if len(pairs) == 0 {
+		return environ
+	}
+
+	mapped := make([]string, 0, len(environ)+len(pairs))
+
+	for _, v := range environ {
+		key, value, _ := strings.Cut(v, "=")
+
+		var renamed []string
+
+		for _, pair := range pairs {
+			if src, dst, _ := strings.Cut(pair, "="); envPrefix+src == key {
+				renamed = append(renamed, dst+"="+value)
+			}
+		}
+
+		if len(renamed) == 0 || keep {
+			mapped = append(mapped, v)
+		}
+
+		mapped = append(mapped, renamed...)
+	}
+
+	return mapped
+}
+
+// Returns names variables are renamed to with `--env-map`.
+func envMapTargets(pairs []string) []string {
+	targets := make([]string, len(pairs))
+
+	for i, pair := range pairs {
+		_, targets[i], _ = strings.Cut(pair, "=")
+	}
+
+	return targets
+}
+
+// Validates `--env-map SRC=DST` pairs. SRC must be a managed variable name,
+// and DST a valid variable name that doesn't collide with managed ones.
+func validateEnvMap(pairs []string) error {
+	for _, v := range pairs {
+		src, dst, ok := strings.Cut(v, "=")
+
+		if !ok || !envKeyPattern.MatchString(dst) {
+			return fmt.Errorf("invalid --env-map value %q (expected SRC=DST)", v)
+		}
+
+		if !slices.Contains(ecsmeta.EnvKeys, src) {
+			return fmt.Errorf("invalid --env-map value %q (%s is not an ECS metadata variable)", v, src)
+		}
+
+		for _, managedKey := range ecsmeta.EnvKeys {
+			if dst == managedKey || dst == envPrefix+managedKey {
+				return fmt.Errorf("invalid --env-map value %q (%s collides with ECS metadata variable)", v, dst)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validates `--prefer` flag value.
+func validatePrefer(prefer string) error {
+	if prefer != "metadata" && prefer != "env" {
+		return fmt.Errorf("invalid --prefer value %q (expected metadata or env)", prefer)
+	}
+
+	return nil
+}
+
+// Registers `--prefer` flag on the command.
+func addPreferFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&envPrefer, "prefer", envPrefer, "Which value wins when both metadata and existing environment variable are set: metadata or env")
+}
+
+// Validates `--exclude` flag values, which must be managed variable names.
+func validateExclude(keys []string) error {
+	for _, key := range keys {
+		if !slices.Contains(ecsmeta.EnvKeys, key) {
+			return fmt.Errorf("invalid --exclude value %q (expected one of %s)", key, strings.Join(ecsmeta.EnvKeys, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Registers `--log-group-template` and `--deployment-key-template` flags on the
+// command.
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logGroupTemplate, "log-group-template", logGroupTemplate, "Template of ECS_LOG_GROUP, with {cluster}, {service}, {family}, {revision}, {task_id}, {container}, {region} and {account_id} placeholders")
+	cmd.RegisterFlagCompletionFunc("log-group-template", cobra.NoFileCompletions)
+
+	cmd.Flags().StringVar(&deploymentKeyTemplate, "deployment-key-template", deploymentKeyTemplate, "Template of ECS_DEPLOYMENT_KEY (empty for tasks not launched by a service), with the same placeholders as --log-group-template")
+	cmd.RegisterFlagCompletionFunc("deployment-key-template", cobra.NoFileCompletions)
+}
+
+// Registers `--cluster-arn-style` flag on the command.
+func addClusterArnStyleFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&clusterArnStyle, "cluster-arn-style", clusterArnStyle, "Export ECS_CLUSTER_NAME as short name or as full ARN (when known): short or full")
+	cmd.RegisterFlagCompletionFunc("cluster-arn-style", cobra.FixedCompletions([]string{"short", "full"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// Registers `--exclude` flag on the command.
+func addExcludeFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&envExclude, "exclude", nil, "Managed variable to omit (e.g. ECS_TASK_ARN), can be given multiple times")
+}
+
+// Returns current environment with managed variables replaced by resolved ones.
+//
+// With `--keep-env`, variables that are already present in the environment are
+// passed through intact, even if empty, and regardless of whether metadata or
+// existing value normally takes precedence for them. Only missing variables
+// are injected then.
+//
+// With `--env-allowlist`, only the listed variables are inherited from the
+// current environment, besides the managed ones.
+func execEnviron(m *ecsmeta.Metadata) []string {
+	metadataEnviron := managedEnviron(m)
+	allowlist := slices.Clone(execEnvAllowlist)
+
+	var environ []string
+
+	if execKeepEnv {
+		for _, v := range metadataEnviron {
+			key, _, _ := strings.Cut(v, "=")
+			allowlist = append(allowlist, key)
+		}
+
+		metadataEnviron = slices.DeleteFunc(metadataEnviron, func(v string) bool {
+			key, _, _ := strings.Cut(v, "=")
+			_, present := os.LookupEnv(key)
+
+			return present
+		})
+
+		slog.Debug("Setting missing environment variables", "metadata", metadataEnviron)
+
+		environ = os.Environ()
+	} else {
+		slog.Debug("Setting environment variables", "metadata", metadataEnviron)
+
+		// Stale values of renamed variables are stripped, same as of managed ones.
+
+		targets := envMapTargets(execEnvMap)
+		environ = slices.DeleteFunc(cleanEnviron(), func(v string) bool {
+			key, _, _ := strings.Cut(v, "=")
+			return slices.Contains(targets, key)
+		})
+	}
+
+	if len(execEnvAllowlist) > 0 {
+		environ = allowedEnviron(environ, allowlist)
+	}
+
+	return mergeEnviron(append(environ, metadataEnviron...), execSet)
+}
+
+// Returns entries of `environ` with keys listed in `allowlist` only.
+func allowedEnviron(environ, allowlist []string) []string {
+	return slices.DeleteFunc(slices.Clone(environ), func(v string) bool {
+		key, _, _ := strings.Cut(v, "=")
+		return !slices.Contains(allowlist, key)
+	})
+}
+
+// Returns `environ` with `KEY=VALUE` pairs of `extra` appended, replacing any
+// earlier entries with the same keys.
+func mergeEnviron(environ, extra []string) []string {
+	if len(extra) == 0 {
+		return environ
+	}
+
+	prefixes := make([]string, 0, len(extra))
+
+	for _, v := range extra {
+		key, _, _ := strings.Cut(v, "=")
+		prefixes = append(prefixes, key+"=")
 	}
 
-	req, err := http.NewRequest("GET", ecsTaskMetadataEndpoint+"/task", nil)
+	environ = slices.DeleteFunc(environ, func(v string) bool {
+		return stringStartsWith(v, prefixes...)
+	})
+
+	return append(environ, extra...)
+}
+
+// Returns arguments read from `path`, one per line. Empty lines and lines
+// starting with # are skipped, other lines are taken verbatim.
+func readArgsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
 
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	var args []string
+
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args = append(args, line)
+	}
+
+	return args, nil
+}
+
+// Returns command line `args` followed by ones read from `--args-file`.
+func resolveExecArgs(args []string) ([]string, error) {
+	if execArgsFile == "" {
+		return args, nil
+	}
+
+	fileArgs, err := readArgsFile(execArgsFile)
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	args = append(slices.Clone(args), fileArgs...)
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given, and args file %s is empty", execArgsFile)
+	}
+
+	return args, nil
+}
+
+// Returns argv running `args` joined with spaces as a shell snippet.
+func shellArgs(args []string) []string {
+	return []string{"/bin/sh", "-c", strings.Join(args, " ")}
+}
+
+// Looks `file` up the same way exec.LookPath does, but in PATH of `environ`
+// rather than of the current process. Falls back to exec.LookPath when
+// `file` contains a slash, or `environ` has no PATH. Errors tell which PATH
+// was searched, as misconfigured PATH is the usual culprit. Like exec.LookPath,
+// it refuses commands found via relative PATH entries (including empty ones)
+// with exec.ErrDot.
+func lookPath(file string, environ []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return exec.LookPath(file)
+	}
+
+	i := slices.IndexFunc(environ, func(v string) bool { return stringStartsWith(v, "PATH=") })
+
+	if i < 0 {
+		path, err := exec.LookPath(file)
+
+		if err != nil {
+			return "", searchedPathError(err, os.Getenv("PATH"))
+		}
+
+		return path, nil
+	}
+
+	searched := strings.TrimPrefix(environ[i], "PATH=")
+
+	for _, dir := range filepath.SplitList(searched) {
+		if dir == "" {
+			dir = "."
+		}
+
+		candidate := filepath.Join(dir, file)
+
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0 {
+			if !filepath.IsAbs(candidate) {
+				return candidate, &exec.Error{Name: file, Err: exec.ErrDot}
+			}
+
+			return candidate, nil
+		}
+	}
+
+	return "", searchedPathError(&exec.Error{Name: file, Err: exec.ErrNotFound}, searched)
+}
+
+// Annotates command lookup error with the searched PATH.
+func searchedPathError(err error, path string) error {
+	if path == "" {
+		return fmt.Errorf("%w (PATH is empty)", err)
+	}
+
+	return fmt.Errorf("%w (PATH=%s)", err, path)
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validates `--set KEY=VALUE` pairs. Keys of managed variables (prefixed with
+// `envPrefix` or not) are rejected unless `force` is true.
+func validateSetFlags(pairs []string, force bool) error {
+	for _, v := range pairs {
+		key, _, ok := strings.Cut(v, "=")
 
-	if err := json.NewDecoder(res.Body).Decode(metadata); err != nil {
+		if !ok || !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid --set value %q (expected KEY=VALUE)", v)
+		}
+
+		if force {
+			continue
+		}
+
+		for _, managedKey := range ecsmeta.EnvKeys {
+			if key == managedKey || key == envPrefix+managedKey {
+				return fmt.Errorf("--set %s collides with ECS metadata variable (use --force to override)", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns ECS task metadata endpoint URI given with `--metadata-uri`, falling
+// back to ECS_CONTAINER_METADATA_URI_V4 environment variable.
+func ecsMetadataEndpoint() string {
+	return firstNonEmpty(metadataURI, os.Getenv(ecsmeta.EndpointEnvVar))
+}
+
+// Returns path of the task metadata file given with `--metadata-file` or as a
+// `file://` endpoint URI, or empty string when metadata is to be fetched from
+// the endpoint.
+func ecsMetadataFile() string {
+	if metadataFile != "" {
+		return metadataFile
+	}
+
+	if path, ok := strings.CutPrefix(ecsMetadataEndpoint(), "file://"); ok {
+		return path
+	}
+
+	return ""
+}
+
+// Returns headers given with `--metadata-header`, falling back to
+// FLUENT_BIT_FOR_ECS_METADATA_HEADER environment variable. Each header is
+// expected in `Name: value` form.
+func ecsMetadataHeader() (http.Header, error) {
+	specs := metadataHeaders
+
+	if len(specs) == 0 {
+		if spec := os.Getenv("FLUENT_BIT_FOR_ECS_METADATA_HEADER"); spec != "" {
+			specs = []string{spec}
+		}
+	}
+
+	header := make(http.Header, len(specs))
+
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = strings.TrimSpace(name)
+
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid metadata header %q (expected Name: value)", spec)
+		}
+
+		header.Add(name, strings.TrimSpace(value))
+	}
+
+	return header, nil
+}
+
+// Registers `--metadata-uri` and `--metadata-header` flags on the command.
+func addMetadataEndpointFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&metadataURI, "metadata-uri", "", "ECS task metadata endpoint URI (overrides ECS_CONTAINER_METADATA_URI_V4)")
+	cmd.RegisterFlagCompletionFunc("metadata-uri", cobra.NoFileCompletions)
+
+	cmd.Flags().StringArrayVar(&metadataHeaders, "metadata-header", nil, "Header (Name: value) sent with ECS task metadata requests (overrides FLUENT_BIT_FOR_ECS_METADATA_HEADER)")
+	cmd.RegisterFlagCompletionFunc("metadata-header", cobra.NoFileCompletions)
+
+	cmd.Flags().StringVar(&metadataFile, "metadata-file", "", "Read task metadata document from the file instead of the endpoint")
+	cmd.MarkFlagsMutuallyExclusive("metadata-file", "metadata-uri")
+
+	cmd.Flags().BoolVar(&metadataStrictSchema, "metadata-strict-schema", false, "Log unexpected fields of ECS task metadata documents")
+
+	cmd.Flags().StringVar(&metadataTaskPath, "metadata-task-path", metadataTaskPath, "Path of the task metadata document, relative to the endpoint URI")
+	cmd.RegisterFlagCompletionFunc("metadata-task-path", cobra.NoFileCompletions)
+}
+
+// Returns ECS task metadata client configured with flags.
+func newMetadataClient(client *http.Client) (*ecsmeta.Client, error) {
+	header, err := ecsMetadataHeader()
+
+	if err != nil {
 		return nil, err
 	}
 
-	// Extract Task ID and AWS Region from Task ARN
+	return &ecsmeta.Client{
+		HTTPClient:   client,
+		Endpoint:     ecsMetadataEndpoint(),
+		TaskPath:     metadataTaskPath,
+		Header:       header,
+		Retries:      metadataRetries,
+		RetryDelay:   metadataRetryDelay,
+		Jitter:       metadataRetryJitter,
+		MaxElapsed:   metadataMaxElapsed,
+		Timeout:      metadataTimeout,
+		StrictSchema: metadataStrictSchema,
+	}, nil
+}
+
+// Retrieves ECS task metadata, and renders log group name and deployment key
+// of it with `--log-group-template` and `--deployment-key-template`. With
+// `--cluster-arn-style=full`, cluster name is replaced with its ARN, when
+// known. Empty metadata is returned as is.
+func getEcsTaskMetadata(ctx context.Context, client *http.Client) (*ecsmeta.Metadata, error) {
+	if err := validateClusterArnStyle(); err != nil {
+		return nil, err
+	}
 
-	taskARN, err := arn.Parse(metadata.EcsTaskARN)
+	metadata, err := fetchEcsTaskMetadata(ctx, client)
 
 	if err != nil {
-		slog.Error("Failed to parse ECS Task ARN", "arn", metadata.EcsTaskARN, "error", err)
-	} else {
-		metadata.AwsRegion = taskARN.Region
-		metadata.EcsTaskID = lastArnPart(taskARN)
+		return metadata, err
 	}
 
-	// Per documentation, the Cluster field can be either an ARN or a short name.
+	applyMetadataFlags(ctx, client, metadata)
+
+	return metadata, nil
+}
 
-	if strings.Contains(metadata.EcsClusterName, "/") {
-		clusterARN, err := arn.Parse(metadata.EcsClusterName)
+// Validates `--cluster-arn-style` flag value.
+func validateClusterArnStyle() error {
+	if clusterArnStyle != "short" && clusterArnStyle != "full" {
+		return fmt.Errorf("invalid --cluster-arn-style value %q (expected short or full)", clusterArnStyle)
+	}
 
-		if err != nil {
-			slog.Error("Failed to parse ECS Cluster ARN", "arn", metadata.EcsClusterName, "error", err)
+	return nil
+}
+
+// Renders log group and deployment key templates, and applies
+// `--enrich-service` and `--cluster-arn-style` to the retrieved metadata.
+// Empty metadata is left as is.
+func applyMetadataFlags(ctx context.Context, client *http.Client, metadata *ecsmeta.Metadata) {
+	if *metadata == (ecsmeta.Metadata{}) {
+		return
+	}
+
+	metadata.EcsLogGroup = metadata.LogGroup(logGroupTemplate)
+	metadata.EcsDeploymentKey = metadata.DeploymentKey(deploymentKeyTemplate)
+
+	if enrichService {
+		enrichServiceMetadata(ctx, client, metadata)
+	}
+
+	if enrichContainerInstance {
+		enrichContainerInstanceMetadata(ctx, client, metadata)
+	}
+
+	if clusterArnStyle == "full" && metadata.EcsClusterARN != "" {
+		metadata.EcsClusterName = metadata.EcsClusterARN
+	}
+}
+
+func fetchEcsTaskMetadata(ctx context.Context, client *http.Client) (*ecsmeta.Metadata, error) {
+	if path := ecsMetadataFile(); path != "" {
+		slog.Debug("Reading ECS task metadata from file", "path", path)
+		return ecsmeta.ReadFile(path)
+	}
+
+	metadataClient, err := newMetadataClient(client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if metadataCache && metadataClient.Endpoint != "" {
+		if metadata, ok := readMetadataCache(metadataCachePath, metadataClient.Endpoint, metadataCacheTTL); ok {
+			slog.Debug("Using cached ECS task metadata", "path", metadataCachePath)
+			return metadata, nil
+		}
+	}
+
+	started := time.Now()
+	metadata, err := metadataClient.Fetch(ctx)
+
+	if errors.Is(err, ecsmeta.ErrEndpointNotSet) {
+		slog.Warn("ECS_CONTAINER_METADATA_URI_V4 environment variable is not set, skipping ECS metadata retrieval")
+		return &ecsmeta.Metadata{}, nil
+	}
+
+	// Includes retries, so tells how long the container start was delayed.
+
+	duration := time.Since(started).Milliseconds()
+
+	if err != nil {
+		slog.Debug("Failed to retrieve ECS task metadata", "duration_ms", duration, "error", err)
+		return nil, err
+	}
+
+	slog.Debug("Retrieved ECS task metadata", "duration_ms", duration)
+
+	if metadataCache && metadataClient.Endpoint != "" {
+		if err := writeMetadataCache(metadataCachePath, metadataClient.Endpoint, metadata); err != nil {
+			slog.Warn("Failed to cache ECS task metadata", "path", metadataCachePath, "error", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// Writes environment variables to `w`, one `KEY=VALUE` per line, sorted.
+// Values are quoted when needed, so that output is safe to eval.
+func printEnviron(w io.Writer, environ []string) error {
+	for _, v := range slices.Sorted(slices.Values(environ)) {
+		key, value, _ := strings.Cut(v, "=")
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", key, shellEscape(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Runs command as a child process, forwarding signals to it, and exits with
+// the same code as the child did.
+func superviseCommand(argv0 string, argv, environ []string, cred *syscall.Credential, forward []os.Signal, stop stopPolicy) error {
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, forward...)
+	defer signal.Stop(signals)
+
+	slog.Debug("Supervising command", "command", argv)
+
+	child := newChildCommand(argv0, argv, environ)
+
+	if cred != nil {
+		child.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+
+	err := superviseChild(child, signals, stop)
+
+	if code, ok := childExitCode(err); ok {
+		return &exitError{code: code, err: err}
+	}
+
+	return err
+}
+
+// Writes environment variables to the file at `path` atomically.
+func writeEnvFile(path string, environ []string) error {
+	warnUnsafeValues(environ)
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return printEnviron(w, environ)
+	})
+}
+
+// Writes environment variables to `stderr` when `path` is `-`, or to the file
+// at `path` otherwise.
+func writeEnvOutput(stderr io.Writer, path string, environ []string) error {
+	if path == "-" {
+		warnUnsafeValues(environ)
+
+		return printEnviron(stderr, environ)
+	}
+
+	return writeEnvFile(path, environ)
+}
+
+// Writes command and its arguments to `w` on a single line, quoted when needed.
+func printCommand(w io.Writer, argv []string) error {
+	quoted := make([]string, len(argv))
+
+	for i, arg := range argv {
+		if arg == "" {
+			quoted[i] = shellQuote(arg)
 		} else {
-			metadata.EcsClusterName = lastArnPart(clusterARN)
+			quoted[i] = shellEscape(arg)
 		}
 	}
 
+	_, err := fmt.Fprintln(w, strings.Join(quoted, " "))
+
+	return err
+}
+
+// Validates `--format` flag value of dry-run output.
+func validateDryRunFormat(format string) error {
+	if format != "env" && format != "json" {
+		return fmt.Errorf("invalid --format %q (expected env or json)", format)
+	}
+
+	return nil
+}
+
+// Writes environment variables of a dry run to `w`, either one `KEY=VALUE` per
+// line sorted by key, or as a JSON object.
+func writeDryRun(w io.Writer, format string, environ []string) error {
+	if err := validateDryRunFormat(format); err != nil {
+		return err
+	}
+
+	if format == "json" {
+		return writeEnviron(w, "json", environ)
+	}
+
+	return printEnviron(w, environ)
+}
+
+// Retrieves ECS task metadata to inject into the command's environment.
+// Returns nil metadata with `--no-metadata`. With `--once-per-boot`, metadata
+// retrieved earlier during the same boot is reused.
+func resolveExecMetadata(ctx context.Context) (*ecsmeta.Metadata, error) {
+	if execNoMetadata {
+		slog.Debug("Skipping ECS task metadata retrieval")
+		return nil, nil
+	}
+
+	if !execOncePerBoot {
+		return fetchExecMetadata(ctx, getEcsTaskMetadata)
+	}
+
+	bootID, err := readBootID()
+
+	if err != nil {
+		slog.Warn("Can't read boot ID, retrieving ECS task metadata", "error", err)
+		return fetchExecMetadata(ctx, getEcsTaskMetadata)
+	}
+
+	if err := validateClusterArnStyle(); err != nil {
+		return nil, err
+	}
+
+	// Sentinel keeps metadata as retrieved, and flags are applied to it on
+	// every invocation, as they might differ between invocations.
+
+	if stored, ok := readBootSentinel(execBootSentinel, bootID); ok {
+		slog.Debug("Reusing ECS task metadata retrieved earlier during this boot", "path", execBootSentinel, "boot_id", bootID)
+
+		return fetchExecMetadata(ctx, func(ctx context.Context, client *http.Client) (*ecsmeta.Metadata, error) {
+			applyMetadataFlags(ctx, client, stored)
+			return stored, nil
+		})
+	}
+
+	return fetchExecMetadata(ctx, func(ctx context.Context, client *http.Client) (*ecsmeta.Metadata, error) {
+		metadata, err := fetchEcsTaskMetadata(ctx, client)
+
+		if err != nil || *metadata == (ecsmeta.Metadata{}) {
+			return metadata, err
+		}
+
+		if err := writeBootSentinel(execBootSentinel, bootID, metadata); err != nil {
+			slog.Warn("Can't write boot sentinel", "path", execBootSentinel, "error", err)
+		}
+
+		applyMetadataFlags(ctx, client, metadata)
+
+		return metadata, nil
+	})
+}
+
+// Retrieves ECS task metadata with `fetch`, bounded by `--startup-timeout`.
+func fetchExecMetadata(ctx context.Context, fetch func(context.Context, *http.Client) (*ecsmeta.Metadata, error)) (*ecsmeta.Metadata, error) {
+	startupCtx := ctx
+
+	if execStartupTimeout > 0 {
+		var cancel context.CancelFunc
+
+		startupCtx, cancel = context.WithTimeout(ctx, execStartupTimeout)
+		defer cancel()
+	}
+
+	// Container might be told to stop while metadata requests are retried, so
+	// stop waiting for them on SIGTERM and SIGINT.
+
+	signalCtx, stop := signal.NotifyContext(startupCtx, unix.SIGTERM, unix.SIGINT)
+	metadata, err := fetch(signalCtx, newHTTPClient())
+	stop()
+
+	if err != nil && ctx.Err() == nil && errors.Is(startupCtx.Err(), context.DeadlineExceeded) {
+		if execStrict {
+			slog.Error("Startup timeout exceeded while retrieving ECS task metadata", "timeout", execStartupTimeout, "error", err)
+			return nil, err
+		}
+
+		slog.Warn("Startup timeout exceeded while retrieving ECS task metadata, proceeding without metadata", "timeout", execStartupTimeout, "error", err)
+		return &ecsmeta.Metadata{}, nil
+	}
+
+	if err != nil && signalCtx.Err() != nil {
+		slog.Error("Interrupted while retrieving ECS task metadata", "error", err)
+		return nil, fmt.Errorf("%w: %w", errExecInterrupted, err)
+	}
+
+	if err != nil {
+		if execStrict || !errors.Is(err, context.DeadlineExceeded) {
+			slog.Error("Can't retrieve ECS task metadata", "error", err)
+			return nil, err
+		}
+
+		slog.Warn("ECS task metadata endpoint timed out, proceeding without metadata", "error", err)
+		metadata = &ecsmeta.Metadata{}
+	}
+
+	if execStrict && *metadata == (ecsmeta.Metadata{}) {
+		return nil, errors.New("ECS task metadata is not available")
+	}
+
 	return metadata, nil
 }
 
 func execCmdRunE(cmd *cobra.Command, args []string) error {
-	argv0, err := exec.LookPath(args[0])
+	if err := validatePrefer(envPrefer); err != nil {
+		return err
+	}
+
+	if err := validateExclude(envExclude); err != nil {
+		return err
+	}
+
+	if err := validateEnvMap(execEnvMap); err != nil {
+		return err
+	}
+
+	if err := validateDryRunFormat(execFormat); err != nil {
+		return err
+	}
+
+	if err := validateSetFlags(execSet, execForce); err != nil {
+		return err
+	}
+
+	forward, err := parseSignals(execForwardSignals)
+
+	if err != nil {
+		return fmt.Errorf("invalid --forward-signal: %w", err)
+	}
+
+	shutdownSignal, err := parseSignal(execShutdownSignal)
+
+	if err != nil {
+		return fmt.Errorf("invalid --shutdown-signal: %w", err)
+	}
+
+	args, err = resolveExecArgs(args)
 
 	if err != nil {
-		slog.Error("Can't find command", "command", args[0], "error", err)
+		slog.Error("Can't read args file", "path", execArgsFile, "error", err)
 		return err
 	}
 
+	if execShell {
+		args = shellArgs(args)
+	}
+
+	if execChdir != "" {
+		if err := os.Chdir(execChdir); err != nil {
+			slog.Error("Can't change working directory", "dir", execChdir, "error", err)
+			return err
+		}
+	}
+
+	var cred *syscall.Credential
+
+	if execUser != "" || execGroup != "" {
+		c, err := resolveCredential(firstNonEmpty(execUser, strconv.Itoa(os.Getuid())), execGroup)
+
+		if err != nil {
+			slog.Error("Can't resolve user or group", "user", execUser, "group", execGroup, "error", err)
+			return err
+		}
+
+		cred = c
+	}
+
+	metadata, err := resolveExecMetadata(cmd.Context())
+
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	environ := execEnviron(metadata)
+
+	// Command is looked up in the PATH it will run with, e.g. given with --set.
+
+	argv0 := args[0]
+
+	if !execSkipLookPath {
+		path, err := lookPath(args[0], environ)
+
+		if err != nil {
+			slog.Error("Can't find command", "command", args[0], "error", err)
+			return err
+		}
+
+		argv0 = path
+	}
+
 	argv := make([]string, 0, len(args))
 	argv = append(argv, argv0)
 	argv = append(argv, args[1:]...)
 
-	metadata, err := getEcsTaskMetadata()
+	if execDryRun {
+		return writeDryRun(cmd.OutOrStdout(), execFormat, managedEnviron(metadata))
+	}
 
-	if err != nil {
-		slog.Error("Can't retrieve ECS task metadata", "error", err)
-		return err
+	if execEnvFile != "" {
+		if err := writeEnvFile(execEnvFile, managedEnviron(metadata)); err != nil {
+			if execStrict {
+				slog.Error("Can't write env file", "path", execEnvFile, "error", err)
+				return err
+			}
+
+			slog.Warn("Can't write env file", "path", execEnvFile, "error", err)
+		}
+	}
+
+	if execOutput != "" {
+		if err := writeEnvOutput(cmd.ErrOrStderr(), execOutput, managedEnviron(metadata)); err != nil {
+			if execStrict {
+				slog.Error("Can't write resolved environment", "output", execOutput, "error", err)
+				return err
+			}
+
+			slog.Warn("Can't write resolved environment", "output", execOutput, "error", err)
+		}
+	}
+
+	if execPrintCommand {
+		if err := printCommand(cmd.ErrOrStderr(), argv); err != nil {
+			return err
+		}
+	}
+
+	if execSupervise {
+		return superviseCommand(argv0, argv, environ, cred, forward, stopPolicy{
+			signal:      shutdownSignal,
+			gracePeriod: execStopGracePeriod,
+			killTimeout: execStopTimeout,
+		})
+	}
+
+	if cred != nil {
+		slog.Debug("Switching credentials", "uid", cred.Uid, "gid", cred.Gid, "groups", cred.Groups)
+
+		if err := setCredential(cred); err != nil {
+			slog.Error("Can't drop privileges", "error", err)
+			return err
+		}
 	}
 
 	slog.Debug("Executing command", "command", argv)
 
-	if err := unix.Exec(argv0, argv, metadata.Environ()); err != nil {
+	if err := unix.Exec(argv0, argv, environ); err != nil {
 		slog.Error("Command execution failed", "command", args[0], "error", err)
 		return err
 	}
@@ -207,4 +1119,47 @@ func init() {
 	rootCmd.AddCommand(execCmd)
 
 	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolVar(&execDryRun, "dry-run", false, "Print ECS metadata variables instead of executing the command")
+	execCmd.Flags().StringVar(&execFormat, "format", execFormat, "With --dry-run, output format: env or json")
+	execCmd.Flags().StringVar(&envPrefix, "env-prefix", "", "Prefix prepended to all injected environment variable names")
+	addMetadataEndpointFlags(execCmd)
+	addMetadataCacheFlags(execCmd)
+	addPreferFlag(execCmd)
+	addExcludeFlag(execCmd)
+	addTemplateFlags(execCmd)
+	addEnrichFlags(execCmd)
+	addClusterArnStyleFlag(execCmd)
+	execCmd.Flags().IntVar(&metadataRetries, "metadata-retries", metadataRetries, "Number of retries of transient ECS metadata endpoint failures")
+	execCmd.Flags().BoolVar(&metadataRetryJitter, "metadata-retry-jitter", metadataRetryJitter, "Randomize delays between ECS metadata retries, to spread load of many tasks starting at once")
+	execCmd.Flags().DurationVar(&metadataMaxElapsed, "metadata-max-elapsed", metadataMaxElapsed, "Stop retrying ECS metadata requests once this long elapsed since the first attempt (0 for no limit)")
+	execCmd.Flags().DurationVar(&metadataTimeout, "metadata-timeout", metadataTimeout, "Timeout of a single ECS metadata endpoint request (0 for no timeout)")
+	execCmd.Flags().StringArrayVar(&execEnvMap, "env-map", nil, "Rename ECS metadata variable (SRC=DST, e.g. AWS_REGION=AWS_DEFAULT_REGION), can be given multiple times")
+	execCmd.Flags().BoolVar(&execEnvMapKeep, "env-map-keep", false, "With --env-map, keep original variables along with renamed ones")
+	execCmd.Flags().BoolVar(&execKeepEnv, "keep-env", false, "Pass through managed variables already present in the environment intact, injecting only missing ones")
+	execCmd.Flags().StringSliceVar(&execEnvAllowlist, "env-allowlist", nil, "Comma-separated variables to inherit from the environment, instead of all of them (ECS metadata is injected regardless)")
+	execCmd.Flags().StringArrayVar(&execSet, "set", nil, "Set additional environment variable (KEY=VALUE), can be given multiple times")
+	execCmd.Flags().BoolVar(&execForce, "force", false, "Allow --set to override ECS metadata variables")
+	execCmd.Flags().StringVar(&execEnvFile, "env-file", "", "Write injected environment variables to the given file before executing the command")
+	execCmd.Flags().StringVar(&execOutput, "output", "", "Write resolved ECS metadata variables to the given file (or - for stderr) before executing the command")
+	execCmd.Flags().BoolVar(&execShell, "shell", false, "Run arguments joined with spaces as a shell snippet with /bin/sh -c")
+	execCmd.Flags().StringVar(&execArgsFile, "args-file", "", "Append arguments read from the given file, one per line, to the command")
+	execCmd.Flags().StringVar(&execChdir, "chdir", "", "Change working directory before resolving and executing the command")
+	execCmd.Flags().StringVar(&execUser, "user", "", "Run command as the given user (name or uid)")
+	execCmd.Flags().StringVar(&execGroup, "group", "", "Run command as the given group (name or gid), defaults to primary group of --user")
+	execCmd.Flags().BoolVar(&execSkipLookPath, "skip-lookpath", false, "Execute command as given, without looking it up in PATH")
+	execCmd.Flags().BoolVar(&execPrintCommand, "print-command", false, "Print resolved command and arguments to stderr before executing it")
+	execCmd.Flags().BoolVar(&execSupervise, "supervise", false, "Run command as a child process forwarding signals (see --forward-signal) to it")
+	execCmd.Flags().DurationVar(&execStopGracePeriod, "stop-grace-period", 0, "With --supervise, delay forwarding SIGTERM to the command by this long (keep it plus --stop-timeout below ECS stopTimeout, 30s by default, after which ECS kills the whole task with SIGKILL)")
+	execCmd.Flags().DurationVar(&execStopTimeout, "stop-timeout", 0, "With --supervise, kill the command if it doesn't exit this long after SIGTERM (0 to never kill, leaving it to ECS once stopTimeout elapses)")
+	execCmd.Flags().StringSliceVar(&execForwardSignals, "forward-signal", execForwardSignals, "With --supervise, comma-separated signals to forward to the command as is (SIGTERM is always handled)")
+	execCmd.Flags().StringVar(&execShutdownSignal, "shutdown-signal", execShutdownSignal, "With --supervise, signal to send to the command upon SIGTERM (e.g. INT)")
+	execCmd.Flags().BoolVar(&execStrict, "strict", false, "Fail instead of proceeding when ECS task metadata can't be retrieved")
+	execCmd.Flags().DurationVar(&execStartupTimeout, "startup-timeout", 0, "Bound total time of ECS task metadata retrieval, including retries (0 for no limit)")
+	execCmd.Flags().BoolVar(&execNoMetadata, "no-metadata", false, "Don't retrieve ECS task metadata, execute the command with inherited environment only")
+
+	execCmd.Flags().BoolVar(&execOncePerBoot, "once-per-boot", false, "Retrieve ECS task metadata once per boot, reusing it on subsequent invocations")
+	execCmd.Flags().StringVar(&execBootSentinel, "boot-sentinel", execBootSentinel, "With --once-per-boot, file to keep ECS task metadata retrieved during the boot in")
+
+	execCmd.MarkFlagsMutuallyExclusive("no-metadata", "strict")
+	execCmd.MarkFlagsMutuallyExclusive("no-metadata", "once-per-boot")
 }
@@ -22,11 +22,21 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,15 +64,150 @@ func TestStringStartsWith(t *testing.T) {
 	})
 }
 
+func TestPrintEnviron(t *testing.T) {
+	t.Run("prints sorted environment one variable per line", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := printEnviron(&buf, []string{"ECS_TASK_ID=deadbeef", "AWS_REGION=aws-region-1", "PATH=/bin"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "AWS_REGION=aws-region-1\nECS_TASK_ID=deadbeef\nPATH=/bin\n", buf.String())
+	})
+
+	t.Run("quotes values with spaces, quotes and $", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := printEnviron(&buf, []string{"ECS_SERVICE_NAME=it's \"x\"", "ECS_TASK_FAMILY=$(reboot)", "ECS_CLUSTER_NAME=foo bar"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "ECS_CLUSTER_NAME='foo bar'\nECS_SERVICE_NAME='it'\\''s \"x\"'\nECS_TASK_FAMILY='$(reboot)'\n", buf.String())
+	})
+}
+
+func TestPrintCommand(t *testing.T) {
+	t.Run("prints command with arguments", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, printCommand(&buf, []string{"/fluent-bit/bin/fluent-bit", "-c", "/fluent-bit/etc/fluent-bit.yml"}))
+		assert.Equal(t, "/fluent-bit/bin/fluent-bit -c /fluent-bit/etc/fluent-bit.yml\n", buf.String())
+	})
+
+	t.Run("quotes arguments when needed", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, printCommand(&buf, []string{"/bin/sh", "-c", "echo $HOME", ""}))
+		assert.Equal(t, "/bin/sh -c 'echo $HOME' ''\n", buf.String())
+	})
+}
+
+func TestWriteDryRun(t *testing.T) {
+	environ := []string{"ECS_TASK_ID=deadbeef", "AWS_REGION=aws-region-1"}
+
+	t.Run("env", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeDryRun(&buf, "env", environ), "expected no error")
+		assert.Equal(t, "AWS_REGION=aws-region-1\nECS_TASK_ID=deadbeef\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeDryRun(&buf, "json", environ), "expected no error")
+		assert.JSONEq(t, `{"AWS_REGION":"aws-region-1","ECS_TASK_ID":"deadbeef"}`, buf.String())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NotNil(t, writeDryRun(&buf, "yaml", environ), "expected an error")
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	t.Run("writes environment readable by owner only", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ecs.env")
+
+		assert.Nil(t, writeEnvFile(path, []string{"ECS_TASK_ID=deadbeef", "AWS_REGION=aws-region-1"}))
+
+		content, err := os.ReadFile(path)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "AWS_REGION=aws-region-1\nECS_TASK_ID=deadbeef\n", string(content))
+
+		info, err := os.Stat(path)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("replaces existing file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "ecs.env")
+
+		assert.Nil(t, os.WriteFile(path, []byte("STALE=1\n"), 0o644))
+		assert.Nil(t, writeEnvFile(path, []string{"ECS_TASK_ID=deadbeef"}))
+
+		content, _ := os.ReadFile(path)
+		entries, _ := os.ReadDir(dir)
+
+		assert.Equal(t, "ECS_TASK_ID=deadbeef\n", string(content))
+		assert.Len(t, entries, 1, "expected no temporary files left behind")
+	})
+
+	t.Run("returns error when directory does not exist", func(t *testing.T) {
+		err := writeEnvFile(filepath.Join(t.TempDir(), "nonexistent", "ecs.env"), nil)
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestWriteEnvOutput(t *testing.T) {
+	environ := []string{"ECS_TASK_ID=deadbeef", "AWS_REGION=aws-region-1"}
+
+	t.Run("writes to stderr when path is -", func(t *testing.T) {
+		var stderr bytes.Buffer
+
+		assert.Nil(t, writeEnvOutput(&stderr, "-", environ))
+		assert.Equal(t, "AWS_REGION=aws-region-1\nECS_TASK_ID=deadbeef\n", stderr.String())
+	})
+
+	t.Run("writes to file", func(t *testing.T) {
+		var stderr bytes.Buffer
+
+		path := filepath.Join(t.TempDir(), "ecs.env")
+
+		assert.Nil(t, writeEnvOutput(&stderr, path, environ))
+
+		content, err := os.ReadFile(path)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "AWS_REGION=aws-region-1\nECS_TASK_ID=deadbeef\n", string(content))
+		assert.Empty(t, stderr.String())
+	})
+}
+
 func TestGetEcsTaskMetadata(t *testing.T) {
-	fakeEcsTaskMetadataServer := func(t *testing.T, statusCode int, body string) *httptest.Server {
+	oldRetryDelay := metadataRetryDelay
+	t.Cleanup(func() { metadataRetryDelay = oldRetryDelay })
+
+	metadataRetryDelay = time.Millisecond
+
+	fakeEcsMetadataServer := func(t *testing.T, statusCode int, taskBody, containerBody string) *httptest.Server {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			assert.Equal(t, "GET", r.Method, "HTTP verb should be GET")
 
+			w.Header().Set("Content-Type", "application/json")
+
 			switch path := r.URL.Path; path {
 			case "/task":
 				w.WriteHeader(statusCode)
-				w.Write([]byte(body))
+				w.Write([]byte(taskBody))
+
+			case "", "/":
+				w.WriteHeader(statusCode)
+				w.Write([]byte(containerBody))
 
 			default:
 				t.Errorf("unexpected URL: %s", path)
@@ -74,164 +219,252 @@ func TestGetEcsTaskMetadata(t *testing.T) {
 		return server
 	}
 
+	fakeEcsTaskMetadataServer := func(t *testing.T, statusCode int, body string) *httptest.Server {
+		return fakeEcsMetadataServer(t, statusCode, body, "{}")
+	}
+
 	t.Run("when ECS_CONTAINER_METADATA_URI_V4 is not set", func(t *testing.T) {
 		os.Unsetenv("ECS_CONTAINER_METADATA_URI_V4")
 
 		t.Run("returns empty metadata", func(t *testing.T) {
-			metadata, err := getEcsTaskMetadata()
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
 			assert.Nil(t, err, "expected no error")
 			assert.NotNil(t, metadata, "expected metadata not to be nil")
-			assert.Equal(t, metadata, &ecsTaskMetadata{})
+			assert.Equal(t, metadata, &ecsmeta.Metadata{})
 		})
 	})
 
-	t.Run("when ECS_CONTAINER_METADATA_URI_V4 is set", func(t *testing.T) {
-		t.Run("when server returns error", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusInternalServerError, "he's not a messiah")
+	t.Run("when --metadata-uri is set", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "cluster-name" }`)
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://169.254.170.2/v4/wazzup")
 
-			metadata, err := getEcsTaskMetadata()
+		oldMetadataURI := metadataURI
+		t.Cleanup(func() { metadataURI = oldMetadataURI })
 
-			assert.NotNil(t, err, "expected an error")
-			assert.Nil(t, metadata, "expected metadata to be nil")
+		metadataURI = server.URL
+
+		t.Run("takes precedence over ECS_CONTAINER_METADATA_URI_V4", func(t *testing.T) {
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, metadata, &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsLogGroup: "/ecs/cluster-name/unknown"})
 		})
 
-		t.Run("when server returns malformed payload", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusOK, "he's a very very naughty boy")
+		t.Run("renders log group with --log-group-template", func(t *testing.T) {
+			oldTemplate := logGroupTemplate
+			t.Cleanup(func() { logGroupTemplate = oldTemplate })
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+			logGroupTemplate = "/aws/ecs/{cluster}"
 
-			metadata, err := getEcsTaskMetadata()
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
-			assert.NotNil(t, err, "expected an error")
-			assert.Nil(t, metadata, "expected metadata to be nil")
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, "/aws/ecs/cluster-name", metadata.EcsLogGroup)
 		})
+	})
 
-		t.Run("when server returns valid payload with cluster name", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
-				{
-					"Cluster":       "cluster-name",
-					"TaskARN":			 "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-					"Family":        "task-family",
-					"Revision":      "161",
-					"ServiceName":   "service-name",
-					"DesiredStatus": "RUNNING"
-				}
-			`)
+	t.Run("when metadata is read from file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "task.json")
+
+		if err := os.WriteFile(path, []byte(`{ "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef" }`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://169.254.170.2/v4/wazzup")
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		oldMetadataURI, oldMetadataFile := metadataURI, metadataFile
+		t.Cleanup(func() { metadataURI, metadataFile = oldMetadataURI, oldMetadataFile })
 
-			metadata, err := getEcsTaskMetadata()
+		expected := &ecsmeta.Metadata{
+			AwsRegion:      "aws-region-1",
+			AwsAccountID:   "123456789123",
+			AwsPartition:   "aws",
+			EcsClusterName: "cluster-name",
+			EcsClusterARN:  "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+			EcsTaskARN:     "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:      "deadbeef",
+			EcsLogGroup:    "/ecs/cluster-name/unknown",
+		}
+
+		t.Run("with --metadata-file", func(t *testing.T) {
+			metadataURI, metadataFile = "", path
+
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
 			assert.Nil(t, err, "expected no error")
-			assert.Equal(t, metadata, &ecsTaskMetadata{
-				AwsRegion:       "aws-region-1",
-				EcsClusterName:  "cluster-name",
-				EcsServiceName:  "service-name",
-				EcsTaskFamily:   "task-family",
-				EcsTaskRevision: "161",
-				EcsTaskARN:      "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-				EcsTaskID:       "deadbeef",
-			})
-		})
-
-		t.Run("when server returns valid payload with cluster name", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
-				{
-					"Cluster":       "arn:aws:ecs:aws-region-2:123456789123:cluster/cluster-name",
-					"TaskARN":			 "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-					"Family":        "task-family",
-					"Revision":      "161",
-					"ServiceName":   "service-name",
-					"DesiredStatus": "RUNNING"
-				}
-			`)
+			assert.Equal(t, expected, metadata)
+		})
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		t.Run("with file:// URI", func(t *testing.T) {
+			metadataURI, metadataFile = "file://"+path, ""
 
-			metadata, err := getEcsTaskMetadata()
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
 			assert.Nil(t, err, "expected no error")
-			assert.Equal(t, metadata, &ecsTaskMetadata{
-				AwsRegion:       "aws-region-1",
-				EcsClusterName:  "cluster-name",
-				EcsServiceName:  "service-name",
-				EcsTaskFamily:   "task-family",
-				EcsTaskRevision: "161",
-				EcsTaskARN:      "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-				EcsTaskID:       "deadbeef",
-			})
-		})
-
-		t.Run("when server returns valid payload with bogus cluster ARN", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
-				{
-					"Cluster":       "wazzup/cluster-name",
-					"TaskARN":			 "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-					"Family":        "task-family",
-					"Revision":      "161",
-					"ServiceName":   "service-name",
-					"DesiredStatus": "RUNNING"
-				}
-			`)
+			assert.Equal(t, expected, metadata)
+		})
+	})
+
+	t.Run("does not change default logger", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "cluster-name", "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef" }`)
+
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		oldFormat, oldLogger := logFormat, slog.Default()
+		t.Cleanup(func() {
+			logFormat = oldFormat
+			slog.SetDefault(oldLogger)
+		})
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		logFormat = "json"
 
-			metadata, err := getEcsTaskMetadata()
+		_, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Same(t, oldLogger, slog.Default())
+	})
+
+	t.Run("with --cluster-arn-style", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name" }`)
+
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		oldStyle := clusterArnStyle
+		t.Cleanup(func() { clusterArnStyle = oldStyle })
+
+		t.Run("exports short name by default", func(t *testing.T) {
+			clusterArnStyle = "short"
+
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
 			assert.Nil(t, err, "expected no error")
-			assert.Equal(t, metadata, &ecsTaskMetadata{
-				AwsRegion:       "aws-region-1",
-				EcsClusterName:  "wazzup/cluster-name",
-				EcsServiceName:  "service-name",
-				EcsTaskFamily:   "task-family",
-				EcsTaskRevision: "161",
-				EcsTaskARN:      "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
-				EcsTaskID:       "deadbeef",
-			})
-		})
-
-		t.Run("when server returns valid payload with bogus task ARN", func(t *testing.T) {
-			server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
-				{
-					"Cluster":       "cluster-name",
-					"TaskARN":       "wazzup/deadbeef",
-					"Family":        "task-family",
-					"Revision":      "161",
-					"ServiceName":   "service-name",
-					"DesiredStatus": "RUNNING"
-				}
-			`)
+			assert.Equal(t, "cluster-name", metadata.EcsClusterName)
+		})
 
-			os.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		t.Run("exports full ARN with full", func(t *testing.T) {
+			clusterArnStyle = "full"
 
-			metadata, err := getEcsTaskMetadata()
+			metadata, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
 
 			assert.Nil(t, err, "expected no error")
-			assert.Equal(t, metadata, &ecsTaskMetadata{
-				EcsClusterName:  "cluster-name",
-				EcsServiceName:  "service-name",
-				EcsTaskFamily:   "task-family",
-				EcsTaskRevision: "161",
-				EcsTaskARN:      "wazzup/deadbeef",
-			})
+			assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name", metadata.EcsClusterName)
+			assert.Equal(t, "/ecs/cluster-name/unknown", metadata.EcsLogGroup, "expected log group to use short name")
+		})
+
+		t.Run("rejects unknown style", func(t *testing.T) {
+			clusterArnStyle = "wazzup"
+
+			_, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+			assert.ErrorContains(t, err, `invalid --cluster-arn-style value "wazzup"`)
 		})
 	})
+
+	t.Run("logs duration of metadata retrieval", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "cluster-name" }`)
+
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		var buf bytes.Buffer
+
+		oldLogger := slog.Default()
+		t.Cleanup(func() { slog.SetDefault(oldLogger) })
+
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+		_, err := getEcsTaskMetadata(context.Background(), newHTTPClient())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Regexp(t, `msg="Retrieved ECS task metadata" duration_ms=\d+`, buf.String())
+	})
+}
+
+func TestEcsMetadataHeader(t *testing.T) {
+	setMetadataHeaders := func(t *testing.T, headers ...string) {
+		t.Helper()
+
+		oldHeaders := metadataHeaders
+		t.Cleanup(func() { metadataHeaders = oldHeaders })
+
+		metadataHeaders = headers
+	}
+
+	t.Run("returns no headers by default", func(t *testing.T) {
+		setMetadataHeaders(t)
+		t.Setenv("FLUENT_BIT_FOR_ECS_METADATA_HEADER", "")
+
+		header, err := ecsMetadataHeader()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Empty(t, header)
+	})
+
+	t.Run("returns header from environment variable", func(t *testing.T) {
+		setMetadataHeaders(t)
+		t.Setenv("FLUENT_BIT_FOR_ECS_METADATA_HEADER", "X-aws-ec2-metadata-token: deadbeef")
+
+		header, err := ecsMetadataHeader()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "deadbeef", header.Get("X-aws-ec2-metadata-token"))
+	})
+
+	t.Run("--metadata-header takes precedence over environment variable", func(t *testing.T) {
+		setMetadataHeaders(t, "Authorization: Bearer cafebabe")
+		t.Setenv("FLUENT_BIT_FOR_ECS_METADATA_HEADER", "X-aws-ec2-metadata-token: deadbeef")
+
+		header, err := ecsMetadataHeader()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "Bearer cafebabe", header.Get("Authorization"))
+		assert.Empty(t, header.Get("X-aws-ec2-metadata-token"))
+	})
+
+	t.Run("returns error on malformed header", func(t *testing.T) {
+		for _, spec := range []string{"Authorization", ": deadbeef", "Bad Name: deadbeef"} {
+			setMetadataHeaders(t, spec)
+
+			_, err := ecsMetadataHeader()
+
+			assert.NotNil(t, err, "expected an error for %q", spec)
+		}
+	})
 }
 
-func TestEcsTaskMetadata_Environ(t *testing.T) {
+func TestExecEnviron(t *testing.T) {
 	resetEnviron := func(t *testing.T) {
 		t.Helper()
 
 		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("AWS_AVAILABILITY_ZONE")
+		os.Unsetenv("AWS_ACCOUNT_ID")
+		os.Unsetenv("AWS_PARTITION")
 		os.Unsetenv("ECS_CLUSTER_NAME")
+		os.Unsetenv("ECS_CLUSTER_ARN")
 		os.Unsetenv("ECS_SERVICE_NAME")
 		os.Unsetenv("ECS_TASK_FAMILY")
 		os.Unsetenv("ECS_TASK_REVISION")
 		os.Unsetenv("ECS_TASK_ARN")
 		os.Unsetenv("ECS_TASK_ID")
+		os.Unsetenv("ECS_TASK_DEFINITION_ARN")
+		os.Unsetenv("ECS_LAUNCH_TYPE")
+		os.Unsetenv("ECS_CONTAINER_INSTANCE_ARN")
+		os.Unsetenv("ECS_TASK_CPU_LIMIT")
+		os.Unsetenv("ECS_TASK_MEMORY_LIMIT")
+		os.Unsetenv("ECS_CONTAINER_NAME")
+		os.Unsetenv("ECS_IMAGE")
+		os.Unsetenv("ECS_IMAGE_DIGEST")
+		os.Unsetenv("ECS_TASK_DESIRED_STATUS")
+		os.Unsetenv("ECS_TASK_KNOWN_STATUS")
+		os.Unsetenv("ECS_PULL_STARTED_AT")
+		os.Unsetenv("ECS_PULL_STOPPED_AT")
+		os.Unsetenv("ECS_TASK_IP")
+		os.Unsetenv("ECS_LOG_GROUP")
+		os.Unsetenv("ECS_DEPLOYMENT_KEY")
+		os.Unsetenv("ECS_SERVICE_DESIRED_COUNT")
+		os.Unsetenv("EC2_INSTANCE_ID")
 	}
 
 	expectedEnviron := func(env ...string) []string {
@@ -247,25 +480,46 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 		return append(
 			cleanEnviron(),
 			valueFor("AWS_REGION"),
+			valueFor("AWS_AVAILABILITY_ZONE"),
+			valueFor("AWS_ACCOUNT_ID"),
+			valueFor("AWS_PARTITION"),
 			valueFor("ECS_CLUSTER_NAME"),
+			valueFor("ECS_CLUSTER_ARN"),
 			valueFor("ECS_SERVICE_NAME"),
 			valueFor("ECS_TASK_FAMILY"),
 			valueFor("ECS_TASK_REVISION"),
 			valueFor("ECS_TASK_ARN"),
 			valueFor("ECS_TASK_ID"),
+			valueFor("ECS_TASK_DEFINITION_ARN"),
+			valueFor("ECS_LAUNCH_TYPE"),
+			valueFor("ECS_CONTAINER_INSTANCE_ARN"),
+			valueFor("ECS_TASK_CPU_LIMIT"),
+			valueFor("ECS_TASK_MEMORY_LIMIT"),
+			valueFor("ECS_CONTAINER_NAME"),
+			valueFor("ECS_IMAGE"),
+			valueFor("ECS_IMAGE_DIGEST"),
+			valueFor("ECS_TASK_DESIRED_STATUS"),
+			valueFor("ECS_TASK_KNOWN_STATUS"),
+			valueFor("ECS_PULL_STARTED_AT"),
+			valueFor("ECS_PULL_STOPPED_AT"),
+			valueFor("ECS_TASK_IP"),
+			valueFor("ECS_LOG_GROUP"),
+			valueFor("ECS_DEPLOYMENT_KEY"),
+			valueFor("ECS_SERVICE_DESIRED_COUNT"),
+			valueFor("EC2_INSTANCE_ID"),
 		)
 	}
 
-	emptyMetadata := ecsTaskMetadata{}
+	emptyMetadata := ecsmeta.Metadata{}
 
 	t.Run("AWS_REGION", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{AwsRegion: "deadbeef"}
+		loadedMetadata := ecsmeta.Metadata{AwsRegion: "deadbeef"}
 
 		t.Run("when AWS_REGION is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("AWS_REGION=deadbeef"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_REGION=deadbeef"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when AWS_REGION is set", func(t *testing.T) {
@@ -273,20 +527,83 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("AWS_REGION", "existing-value")
 
-			assert.Equal(t, expectedEnviron("AWS_REGION=existing-value"), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("AWS_REGION=existing-value"), loadedMetadata.Environ(),
-				"does not overwrite existing AWS_REGION environment variable")
+			assert.Equal(t, expectedEnviron("AWS_REGION=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_REGION=deadbeef"), execEnviron(&loadedMetadata),
+				"overwrites existing AWS_REGION environment variable")
+		})
+	})
+
+	t.Run("AWS_AVAILABILITY_ZONE", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{AwsAvailabilityZone: "deadbeef"}
+
+		t.Run("when AWS_AVAILABILITY_ZONE is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_AVAILABILITY_ZONE=deadbeef"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when AWS_AVAILABILITY_ZONE is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("AWS_AVAILABILITY_ZONE", "existing-value")
+
+			assert.Equal(t, expectedEnviron("AWS_AVAILABILITY_ZONE=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_AVAILABILITY_ZONE=deadbeef"), execEnviron(&loadedMetadata),
+				"overwrites existing AWS_AVAILABILITY_ZONE environment variable")
+		})
+	})
+
+	t.Run("AWS_ACCOUNT_ID", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{AwsAccountID: "123456789123"}
+
+		t.Run("when AWS_ACCOUNT_ID is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_ACCOUNT_ID=123456789123"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when AWS_ACCOUNT_ID is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("AWS_ACCOUNT_ID", "existing-value")
+
+			assert.Equal(t, expectedEnviron("AWS_ACCOUNT_ID=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_ACCOUNT_ID=123456789123"), execEnviron(&loadedMetadata),
+				"overwrites existing AWS_ACCOUNT_ID environment variable")
+		})
+	})
+
+	t.Run("AWS_PARTITION", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{AwsPartition: "aws-cn"}
+
+		t.Run("when AWS_PARTITION is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_PARTITION=aws-cn"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when AWS_PARTITION is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("AWS_PARTITION", "existing-value")
+
+			assert.Equal(t, expectedEnviron("AWS_PARTITION=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("AWS_PARTITION=aws-cn"), execEnviron(&loadedMetadata),
+				"overwrites existing AWS_PARTITION environment variable")
 		})
 	})
 
 	t.Run("ECS_CLUSTER_NAME", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsClusterName: "deadbeef"}
+		loadedMetadata := ecsmeta.Metadata{EcsClusterName: "deadbeef"}
 
 		t.Run("when ECS_CLUSTER_NAME is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=deadbeef"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=deadbeef"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when ECS_CLUSTER_NAME is set", func(t *testing.T) {
@@ -294,20 +611,41 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_CLUSTER_NAME", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=existing-value"), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=existing-value"), loadedMetadata.Environ(),
-				"does not overwrite existing ECS_CLUSTER_NAME environment variable")
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_NAME=deadbeef"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_CLUSTER_NAME environment variable")
+		})
+	})
+
+	t.Run("ECS_CLUSTER_ARN", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsClusterARN: "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name"}
+
+		t.Run("when ECS_CLUSTER_ARN is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_ARN=arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_CLUSTER_ARN is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_CLUSTER_ARN", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_ARN=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CLUSTER_ARN=arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_CLUSTER_ARN environment variable")
 		})
 	})
 
 	t.Run("ECS_SERVICE_NAME", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsServiceName: "deadbeef"}
+		loadedMetadata := ecsmeta.Metadata{EcsServiceName: "deadbeef"}
 
 		t.Run("when ECS_SERVICE_NAME is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=deadbeef"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=deadbeef"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when ECS_SERVICE_NAME is set", func(t *testing.T) {
@@ -315,20 +653,20 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_SERVICE_NAME", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=existing-value"), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=existing-value"), loadedMetadata.Environ(),
-				"does not overwrite existing ECS_SERVICE_NAME environment variable")
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_NAME=deadbeef"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_SERVICE_NAME environment variable")
 		})
 	})
 
 	t.Run("ECS_TASK_FAMILY", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsTaskFamily: "deadbeef"}
+		loadedMetadata := ecsmeta.Metadata{EcsTaskFamily: "deadbeef"}
 
 		t.Run("when ECS_TASK_FAMILY is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=deadbeef"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=deadbeef"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when ECS_TASK_FAMILY is set", func(t *testing.T) {
@@ -336,20 +674,20 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_TASK_FAMILY", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=existing-value"), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=deadbeef"), loadedMetadata.Environ(),
+			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_FAMILY=deadbeef"), execEnviron(&loadedMetadata),
 				"overwrites existing ECS_TASK_FAMILY environment variable")
 		})
 	})
 
 	t.Run("ECS_TASK_REVISION", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsTaskRevision: "161"}
+		loadedMetadata := ecsmeta.Metadata{EcsTaskRevision: "161"}
 
 		t.Run("when ECS_TASK_REVISION is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=161"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=161"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when ECS_TASK_REVISION is set", func(t *testing.T) {
@@ -357,23 +695,23 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_TASK_REVISION", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=existing-value"), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=161"), loadedMetadata.Environ(),
+			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_REVISION=161"), execEnviron(&loadedMetadata),
 				"overwrites existing ECS_TASK_REVISION environment variable")
 		})
 	})
 
 	t.Run("ECS_TASK_ARN", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsTaskARN: "arn:aws:example"}
+		loadedMetadata := ecsmeta.Metadata{EcsTaskARN: "arn:aws:example"}
 
 		t.Run("when ECS_TASK_ARN is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
 
 			assert.Equal(t,
 				expectedEnviron("ECS_TASK_ARN=arn:aws:example"),
-				loadedMetadata.Environ(),
+				execEnviron(&loadedMetadata),
 			)
 		})
 
@@ -382,24 +720,24 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_TASK_ARN", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_TASK_ARN=existing-value"), emptyMetadata.Environ())
+			assert.Equal(t, expectedEnviron("ECS_TASK_ARN=existing-value"), execEnviron(&emptyMetadata))
 
 			assert.Equal(t,
 				expectedEnviron("ECS_TASK_ARN=arn:aws:example"),
-				loadedMetadata.Environ(),
+				execEnviron(&loadedMetadata),
 				"overwrites existing ECS_TASK_ARN environment variable",
 			)
 		})
 	})
 
 	t.Run("ECS_TASK_ID", func(t *testing.T) {
-		loadedMetadata := ecsTaskMetadata{EcsTaskID: "deadbeef"}
+		loadedMetadata := ecsmeta.Metadata{EcsTaskID: "deadbeef"}
 
 		t.Run("when ECS_TASK_ID is not set", func(t *testing.T) {
 			resetEnviron(t)
 
-			assert.Equal(t, expectedEnviron(), emptyMetadata.Environ())
-			assert.Equal(t, expectedEnviron("ECS_TASK_ID=deadbeef"), loadedMetadata.Environ())
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_ID=deadbeef"), execEnviron(&loadedMetadata))
 		})
 
 		t.Run("when ECS_TASK_ID is set", func(t *testing.T) {
@@ -407,13 +745,1172 @@ func TestEcsTaskMetadata_Environ(t *testing.T) {
 
 			t.Setenv("ECS_TASK_ID", "existing-value")
 
-			assert.Equal(t, expectedEnviron("ECS_TASK_ID=existing-value"), emptyMetadata.Environ())
+			assert.Equal(t, expectedEnviron("ECS_TASK_ID=existing-value"), execEnviron(&emptyMetadata))
 
 			assert.Equal(t,
 				expectedEnviron("ECS_TASK_ID=deadbeef"),
-				loadedMetadata.Environ(),
+				execEnviron(&loadedMetadata),
 				"overwrites existing ECS_TASK_ID environment variable",
 			)
 		})
 	})
+
+	t.Run("ECS_TASK_DEFINITION_ARN", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161"}
+
+		t.Run("when ECS_TASK_DEFINITION_ARN is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_DEFINITION_ARN=arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_DEFINITION_ARN is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_DEFINITION_ARN", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_DEFINITION_ARN=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_DEFINITION_ARN=arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_DEFINITION_ARN environment variable")
+		})
+	})
+
+	t.Run("ECS_LAUNCH_TYPE", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsLaunchType: "FARGATE"}
+
+		t.Run("when ECS_LAUNCH_TYPE is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_LAUNCH_TYPE=FARGATE"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_LAUNCH_TYPE is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_LAUNCH_TYPE", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_LAUNCH_TYPE=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_LAUNCH_TYPE=FARGATE"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_LAUNCH_TYPE environment variable")
+		})
+	})
+
+	t.Run("ECS_CONTAINER_INSTANCE_ARN", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsContainerInstanceARN: "arn:aws:example"}
+
+		t.Run("when ECS_CONTAINER_INSTANCE_ARN is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_INSTANCE_ARN=arn:aws:example"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_CONTAINER_INSTANCE_ARN is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_CONTAINER_INSTANCE_ARN", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_INSTANCE_ARN=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_INSTANCE_ARN=arn:aws:example"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_CONTAINER_INSTANCE_ARN environment variable")
+		})
+	})
+
+	t.Run("ECS_TASK_CPU_LIMIT", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskLimits: ecsmeta.TaskLimits{CPU: "0.25"}}
+
+		t.Run("when ECS_TASK_CPU_LIMIT is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_CPU_LIMIT=0.25"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_CPU_LIMIT is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_CPU_LIMIT", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_CPU_LIMIT=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_CPU_LIMIT=0.25"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_CPU_LIMIT environment variable")
+		})
+	})
+
+	t.Run("ECS_TASK_MEMORY_LIMIT", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskLimits: ecsmeta.TaskLimits{Memory: "512"}}
+
+		t.Run("when ECS_TASK_MEMORY_LIMIT is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_MEMORY_LIMIT=512"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_MEMORY_LIMIT is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_MEMORY_LIMIT", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_MEMORY_LIMIT=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_MEMORY_LIMIT=512"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_MEMORY_LIMIT environment variable")
+		})
+	})
+
+	t.Run("ECS_CONTAINER_NAME", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsContainerName: "log_router"}
+
+		t.Run("when ECS_CONTAINER_NAME is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_NAME=log_router"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_CONTAINER_NAME is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_CONTAINER_NAME", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_NAME=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_CONTAINER_NAME=log_router"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_CONTAINER_NAME environment variable")
+		})
+	})
+
+	t.Run("ECS_IMAGE", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsImage: "fluent/fluent-bit:latest"}
+
+		t.Run("when ECS_IMAGE is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_IMAGE=fluent/fluent-bit:latest"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_IMAGE is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_IMAGE", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_IMAGE=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_IMAGE=fluent/fluent-bit:latest"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_IMAGE environment variable")
+		})
+	})
+
+	t.Run("ECS_IMAGE_DIGEST", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsImageDigest: "sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de"}
+
+		t.Run("when ECS_IMAGE_DIGEST is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_IMAGE_DIGEST=sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_IMAGE_DIGEST is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_IMAGE_DIGEST", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_IMAGE_DIGEST=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_IMAGE_DIGEST=sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_IMAGE_DIGEST environment variable")
+		})
+	})
+
+	t.Run("ECS_TASK_DESIRED_STATUS", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskDesiredStatus: "STOPPED"}
+
+		t.Run("when ECS_TASK_DESIRED_STATUS is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_DESIRED_STATUS=STOPPED"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_DESIRED_STATUS is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_DESIRED_STATUS", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_DESIRED_STATUS=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_DESIRED_STATUS=STOPPED"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_DESIRED_STATUS environment variable")
+		})
+	})
+
+	t.Run("ECS_TASK_KNOWN_STATUS", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskKnownStatus: "RUNNING"}
+
+		t.Run("when ECS_TASK_KNOWN_STATUS is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_KNOWN_STATUS=RUNNING"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_KNOWN_STATUS is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_KNOWN_STATUS", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_KNOWN_STATUS=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_KNOWN_STATUS=RUNNING"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_KNOWN_STATUS environment variable")
+		})
+	})
+
+	t.Run("ECS_PULL_STARTED_AT", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsPullStartedAt: ecsmeta.Timestamp{Time: time.Date(2020, 10, 2, 0, 43, 6, 202617438, time.UTC)}}
+
+		t.Run("when ECS_PULL_STARTED_AT is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_PULL_STARTED_AT=2020-10-02T00:43:06Z"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_PULL_STARTED_AT is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_PULL_STARTED_AT", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_PULL_STARTED_AT=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_PULL_STARTED_AT=2020-10-02T00:43:06Z"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_PULL_STARTED_AT environment variable")
+		})
+	})
+
+	t.Run("ECS_PULL_STOPPED_AT", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsPullStoppedAt: ecsmeta.Timestamp{Time: time.Date(2020, 10, 2, 0, 43, 6, 31319424, time.UTC)}}
+
+		t.Run("when ECS_PULL_STOPPED_AT is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_PULL_STOPPED_AT=2020-10-02T00:43:06Z"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_PULL_STOPPED_AT is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_PULL_STOPPED_AT", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_PULL_STOPPED_AT=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_PULL_STOPPED_AT=2020-10-02T00:43:06Z"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_PULL_STOPPED_AT environment variable")
+		})
+	})
+
+	t.Run("ECS_TASK_IP", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsTaskIP: "10.0.0.108"}
+
+		t.Run("when ECS_TASK_IP is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_IP=10.0.0.108"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_TASK_IP is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_TASK_IP", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_TASK_IP=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_TASK_IP=10.0.0.108"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_TASK_IP environment variable")
+		})
+	})
+
+	t.Run("ECS_LOG_GROUP", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsLogGroup: "/ecs/cluster-name/service-name"}
+
+		t.Run("when ECS_LOG_GROUP is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_LOG_GROUP=/ecs/cluster-name/service-name"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_LOG_GROUP is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_LOG_GROUP", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_LOG_GROUP=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_LOG_GROUP=/ecs/cluster-name/service-name"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_LOG_GROUP environment variable")
+		})
+	})
+
+	t.Run("ECS_DEPLOYMENT_KEY", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsDeploymentKey: "cluster-name/service-name/task-family:161"}
+
+		t.Run("when ECS_DEPLOYMENT_KEY is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_DEPLOYMENT_KEY=cluster-name/service-name/task-family:161"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_DEPLOYMENT_KEY is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_DEPLOYMENT_KEY", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_DEPLOYMENT_KEY=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_DEPLOYMENT_KEY=cluster-name/service-name/task-family:161"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_DEPLOYMENT_KEY environment variable")
+		})
+	})
+
+	t.Run("ECS_SERVICE_DESIRED_COUNT", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{EcsServiceDesiredCount: "3"}
+
+		t.Run("when ECS_SERVICE_DESIRED_COUNT is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_DESIRED_COUNT=3"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when ECS_SERVICE_DESIRED_COUNT is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("ECS_SERVICE_DESIRED_COUNT", "existing-value")
+
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_DESIRED_COUNT=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("ECS_SERVICE_DESIRED_COUNT=3"), execEnviron(&loadedMetadata),
+				"overwrites existing ECS_SERVICE_DESIRED_COUNT environment variable")
+		})
+	})
+
+	t.Run("EC2_INSTANCE_ID", func(t *testing.T) {
+		loadedMetadata := ecsmeta.Metadata{Ec2InstanceID: "i-0123456789abcdef0"}
+
+		t.Run("when EC2_INSTANCE_ID is not set", func(t *testing.T) {
+			resetEnviron(t)
+
+			assert.Equal(t, expectedEnviron(), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("EC2_INSTANCE_ID=i-0123456789abcdef0"), execEnviron(&loadedMetadata))
+		})
+
+		t.Run("when EC2_INSTANCE_ID is set", func(t *testing.T) {
+			resetEnviron(t)
+
+			t.Setenv("EC2_INSTANCE_ID", "existing-value")
+
+			assert.Equal(t, expectedEnviron("EC2_INSTANCE_ID=existing-value"), execEnviron(&emptyMetadata))
+			assert.Equal(t, expectedEnviron("EC2_INSTANCE_ID=i-0123456789abcdef0"), execEnviron(&loadedMetadata),
+				"overwrites existing EC2_INSTANCE_ID environment variable")
+		})
+	})
+}
+
+func TestExecEnviron_WithPrefix(t *testing.T) {
+	setEnvPrefix := func(t *testing.T, prefix string) {
+		t.Helper()
+
+		oldPrefix := envPrefix
+		t.Cleanup(func() { envPrefix = oldPrefix })
+
+		envPrefix = prefix
+	}
+
+	t.Run("prefixes injected variables", func(t *testing.T) {
+		setEnvPrefix(t, "MYAPP_")
+
+		metadata := ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsTaskID: "deadbeef"}
+		environ := managedEnviron(&metadata)
+
+		assert.Contains(t, environ, "MYAPP_ECS_CLUSTER_NAME=cluster-name")
+		assert.Contains(t, environ, "MYAPP_ECS_TASK_ID=deadbeef")
+		assert.NotContains(t, environ, "ECS_CLUSTER_NAME=cluster-name")
+		assert.Len(t, environ, len(ecsmeta.EnvKeys))
+	})
+
+	t.Run("falls back to existing prefixed variables", func(t *testing.T) {
+		setEnvPrefix(t, "MYAPP_")
+
+		t.Setenv("ECS_SERVICE_NAME", "unprefixed-value")
+		t.Setenv("MYAPP_ECS_SERVICE_NAME", "prefixed-value")
+
+		metadata := ecsmeta.Metadata{}
+
+		assert.Contains(t, managedEnviron(&metadata), "MYAPP_ECS_SERVICE_NAME=prefixed-value")
+	})
+
+	t.Run("strips both prefixed and unprefixed variables", func(t *testing.T) {
+		setEnvPrefix(t, "MYAPP_")
+
+		t.Setenv("ECS_TASK_ID", "unprefixed-value")
+		t.Setenv("MYAPP_ECS_TASK_ID", "prefixed-value")
+		t.Setenv("MYAPP_UNMANAGED", "unmanaged-value")
+
+		environ := cleanEnviron()
+
+		assert.NotContains(t, environ, "ECS_TASK_ID=unprefixed-value")
+		assert.NotContains(t, environ, "MYAPP_ECS_TASK_ID=prefixed-value")
+		assert.Contains(t, environ, "MYAPP_UNMANAGED=unmanaged-value")
+	})
+}
+
+func TestManagedEnviron_Prefer(t *testing.T) {
+	setPrefer := func(t *testing.T, prefer string) {
+		t.Helper()
+
+		oldPrefer := envPrefer
+		t.Cleanup(func() { envPrefer = oldPrefer })
+
+		envPrefer = prefer
+	}
+
+	metadata := ecsmeta.Metadata{
+		AwsRegion:            "aws-region-1",
+		AwsAvailabilityZone:  "aws-region-1a",
+		AwsAccountID:         "123456789123",
+		AwsPartition:         "aws",
+		EcsClusterName:       "cluster-name",
+		EcsClusterARN:        "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+		EcsServiceName:       "service-name",
+		EcsTaskFamily:        "task-family",
+		EcsTaskRevision:      "161",
+		EcsTaskARN:           "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+		EcsTaskID:            "deadbeef",
+		EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+		EcsLaunchType:        "EC2",
+		EcsTaskLimits:        ecsmeta.TaskLimits{CPU: "0.25", Memory: "512"},
+		EcsTaskDesiredStatus: "RUNNING",
+		EcsTaskKnownStatus:   "RUNNING",
+
+		EcsContainerInstanceARN: "arn:aws:ecs:aws-region-1:123456789123:container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1",
+		EcsContainerName:        "log_router",
+		EcsImage:                "fluent/fluent-bit:latest",
+		EcsImageDigest:          "sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de",
+		EcsPullStartedAt:        ecsmeta.Timestamp{Time: time.Date(2020, 10, 2, 0, 43, 6, 0, time.UTC)},
+		EcsPullStoppedAt:        ecsmeta.Timestamp{Time: time.Date(2020, 10, 2, 0, 43, 7, 0, time.UTC)},
+		EcsTaskIP:               "10.0.0.108",
+		EcsLogGroup:             "/ecs/cluster-name/service-name",
+		EcsDeploymentKey:        "cluster-name/service-name/task-family:161",
+		EcsServiceDesiredCount:  "3",
+		Ec2InstanceID:           "i-0123456789abcdef0",
+	}
+
+	t.Run("when --prefer=metadata", func(t *testing.T) {
+		setPrefer(t, "metadata")
+
+		for _, key := range ecsmeta.EnvKeys {
+			t.Setenv(key, "existing-value")
+		}
+
+		for _, v := range managedEnviron(&metadata) {
+			assert.NotRegexp(t, "=existing-value$", v, "overwrites existing environment variable")
+		}
+	})
+
+	t.Run("when --prefer=env", func(t *testing.T) {
+		setPrefer(t, "env")
+
+		for _, key := range ecsmeta.EnvKeys {
+			t.Setenv(key, "existing-value")
+		}
+
+		for _, v := range managedEnviron(&metadata) {
+			assert.Regexp(t, "=existing-value$", v, "does not overwrite existing environment variable")
+		}
+
+		for _, key := range ecsmeta.EnvKeys {
+			os.Unsetenv(key)
+		}
+
+		for _, v := range managedEnviron(&metadata) {
+			assert.NotRegexp(t, "=$", v, "falls back to metadata")
+		}
+	})
+}
+
+func TestManagedEnviron_Exclude(t *testing.T) {
+	oldExclude := envExclude
+	t.Cleanup(func() { envExclude = oldExclude })
+
+	envExclude = []string{"ECS_TASK_ARN"}
+
+	metadata := ecsmeta.Metadata{EcsTaskARN: "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef", EcsTaskID: "deadbeef"}
+
+	t.Run("omits excluded variables", func(t *testing.T) {
+		environ := managedEnviron(&metadata)
+
+		assert.Contains(t, environ, "ECS_TASK_ID=deadbeef")
+		assert.NotContains(t, environ, "ECS_TASK_ARN=arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef")
+		assert.Len(t, environ, len(ecsmeta.EnvKeys)-1)
+	})
+
+	t.Run("strips existing values of excluded variables", func(t *testing.T) {
+		t.Setenv("ECS_TASK_ARN", "existing-value")
+
+		for _, v := range execEnviron(&metadata) {
+			assert.False(t, stringStartsWith(v, "ECS_TASK_ARN="), "expected %q to be stripped", v)
+		}
+	})
+}
+
+func TestValidateExclude(t *testing.T) {
+	assert.Nil(t, validateExclude(nil))
+	assert.Nil(t, validateExclude([]string{"ECS_TASK_ARN", "ECS_TASK_IP"}))
+	assert.ErrorContains(t, validateExclude([]string{"ECS_TASK_ARN", "PATH"}), `invalid --exclude value "PATH"`)
+}
+
+func TestMapEnviron(t *testing.T) {
+	environ := []string{"AWS_REGION=aws-region-1", "ECS_TASK_ID=deadbeef"}
+
+	t.Run("returns environ as is without pairs", func(t *testing.T) {
+		assert.Equal(t, environ, mapEnviron(environ, nil, false))
+	})
+
+	t.Run("renames variables", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"AWS_DEFAULT_REGION=aws-region-1", "ECS_TASK_ID=deadbeef"},
+			mapEnviron(environ, []string{"AWS_REGION=AWS_DEFAULT_REGION"}, false))
+	})
+
+	t.Run("keeps originals with keep", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"AWS_REGION=aws-region-1", "AWS_DEFAULT_REGION=aws-region-1", "ECS_TASK_ID=deadbeef"},
+			mapEnviron(environ, []string{"AWS_REGION=AWS_DEFAULT_REGION"}, true))
+	})
+
+	t.Run("renames variable to several names", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"AWS_DEFAULT_REGION=aws-region-1", "REGION=aws-region-1", "ECS_TASK_ID=deadbeef"},
+			mapEnviron(environ, []string{"AWS_REGION=AWS_DEFAULT_REGION", "AWS_REGION=REGION"}, false))
+	})
+
+	t.Run("renames prefixed variables", func(t *testing.T) {
+		oldPrefix := envPrefix
+		t.Cleanup(func() { envPrefix = oldPrefix })
+
+		envPrefix = "MYAPP_"
+
+		assert.Equal(t,
+			[]string{"AWS_DEFAULT_REGION=aws-region-1"},
+			mapEnviron([]string{"MYAPP_AWS_REGION=aws-region-1"}, []string{"AWS_REGION=AWS_DEFAULT_REGION"}, false))
+	})
+}
+
+func TestValidateEnvMap(t *testing.T) {
+	assert.Nil(t, validateEnvMap(nil))
+	assert.Nil(t, validateEnvMap([]string{"AWS_REGION=AWS_DEFAULT_REGION"}))
+
+	assert.ErrorContains(t, validateEnvMap([]string{"AWS_REGION"}), "expected SRC=DST")
+	assert.ErrorContains(t, validateEnvMap([]string{"AWS_REGION=MY-REGION"}), "expected SRC=DST")
+	assert.ErrorContains(t, validateEnvMap([]string{"PATH=MY_PATH"}), "PATH is not an ECS metadata variable")
+	assert.ErrorContains(t, validateEnvMap([]string{"ECS_TASK_ID=ECS_TASK_ARN"}), "ECS_TASK_ARN collides with ECS metadata variable")
+}
+
+func TestExecEnviron_EnvMap(t *testing.T) {
+	oldEnvMap := execEnvMap
+	t.Cleanup(func() { execEnvMap = oldEnvMap })
+
+	execEnvMap = []string{"AWS_REGION=AWS_DEFAULT_REGION"}
+
+	t.Setenv("AWS_DEFAULT_REGION", "stale-region")
+
+	environ := execEnviron(&ecsmeta.Metadata{AwsRegion: "aws-region-1"})
+
+	assert.Contains(t, environ, "AWS_DEFAULT_REGION=aws-region-1")
+	assert.NotContains(t, environ, "AWS_DEFAULT_REGION=stale-region")
+	assert.NotContains(t, environ, "AWS_REGION=aws-region-1")
+}
+
+func TestValidatePrefer(t *testing.T) {
+	for _, prefer := range []string{"metadata", "env"} {
+		assert.Nil(t, validatePrefer(prefer), "expected no error for %q", prefer)
+	}
+
+	assert.NotNil(t, validatePrefer("wazzup"), "expected an error")
+}
+
+func TestExecEnviron_KeepEnv(t *testing.T) {
+	setKeepEnv := func(t *testing.T, keepEnv bool) {
+		t.Helper()
+
+		oldKeepEnv := execKeepEnv
+		t.Cleanup(func() { execKeepEnv = oldKeepEnv })
+
+		execKeepEnv = keepEnv
+	}
+
+	metadata := ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsTaskID: "deadbeef", EcsTaskFamily: "task-family"}
+
+	t.Run("passes through present variables intact", func(t *testing.T) {
+		setKeepEnv(t, true)
+
+		t.Setenv("ECS_TASK_ID", "existing-value")
+		t.Setenv("ECS_TASK_FAMILY", "")
+
+		environ := execEnviron(&metadata)
+
+		assert.Contains(t, environ, "ECS_TASK_ID=existing-value")
+		assert.NotContains(t, environ, "ECS_TASK_ID=deadbeef")
+		assert.Contains(t, environ, "ECS_TASK_FAMILY=")
+		assert.NotContains(t, environ, "ECS_TASK_FAMILY=task-family")
+	})
+
+	t.Run("injects missing variables", func(t *testing.T) {
+		setKeepEnv(t, true)
+
+		t.Setenv("ECS_CLUSTER_NAME", "")
+		os.Unsetenv("ECS_CLUSTER_NAME")
+
+		assert.Contains(t, execEnviron(&metadata), "ECS_CLUSTER_NAME=cluster-name")
+	})
+
+	t.Run("injects each variable once", func(t *testing.T) {
+		setKeepEnv(t, true)
+
+		t.Setenv("ECS_TASK_ID", "existing-value")
+
+		count := 0
+
+		for _, v := range execEnviron(&metadata) {
+			if stringStartsWith(v, "ECS_TASK_ID=") {
+				count++
+			}
+		}
+
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestExecEnviron_Allowlist(t *testing.T) {
+	setAllowlist := func(t *testing.T, allowlist ...string) {
+		t.Helper()
+
+		oldAllowlist := execEnvAllowlist
+		t.Cleanup(func() { execEnvAllowlist = oldAllowlist })
+
+		execEnvAllowlist = allowlist
+	}
+
+	metadata := ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsTaskID: "deadbeef"}
+
+	t.Run("inherits listed variables only", func(t *testing.T) {
+		setAllowlist(t, "PATH", "FLB_LOG_LEVEL")
+
+		t.Setenv("PATH", "/usr/bin")
+		t.Setenv("FLB_LOG_LEVEL", "debug")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "wazzup")
+
+		environ := execEnviron(&metadata)
+
+		assert.Contains(t, environ, "PATH=/usr/bin")
+		assert.Contains(t, environ, "FLB_LOG_LEVEL=debug")
+		assert.NotContains(t, environ, "AWS_SECRET_ACCESS_KEY=wazzup")
+		assert.Contains(t, environ, "ECS_CLUSTER_NAME=cluster-name")
+		assert.Contains(t, environ, "ECS_TASK_ID=deadbeef")
+		assert.Len(t, environ, 2+len(ecsmeta.EnvKeys))
+	})
+
+	t.Run("passes through present managed variables with --keep-env", func(t *testing.T) {
+		setAllowlist(t, "PATH")
+
+		oldKeepEnv := execKeepEnv
+		t.Cleanup(func() { execKeepEnv = oldKeepEnv })
+
+		execKeepEnv = true
+
+		t.Setenv("ECS_TASK_ID", "existing-value")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "wazzup")
+
+		environ := execEnviron(&metadata)
+
+		assert.Contains(t, environ, "ECS_TASK_ID=existing-value")
+		assert.Contains(t, environ, "ECS_CLUSTER_NAME=cluster-name")
+		assert.NotContains(t, environ, "AWS_SECRET_ACCESS_KEY=wazzup")
+	})
+
+	t.Run("keeps --set variables", func(t *testing.T) {
+		setAllowlist(t, "PATH")
+
+		oldSet := execSet
+		t.Cleanup(func() { execSet = oldSet })
+
+		execSet = []string{"EXTRA=1"}
+
+		assert.Contains(t, execEnviron(&metadata), "EXTRA=1")
+	})
+}
+
+func TestMergeEnviron(t *testing.T) {
+	t.Run("appends extra variables", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"HOME=/root", "LOG_STREAM_PREFIX=app"},
+			mergeEnviron([]string{"HOME=/root"}, []string{"LOG_STREAM_PREFIX=app"}),
+		)
+	})
+
+	t.Run("replaces existing variables", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"HOME=/root", "ECS_TASK_ID=custom", "LOG_STREAM_PREFIX=app"},
+			mergeEnviron([]string{"ECS_TASK_ID=deadbeef", "HOME=/root", "LOG_STREAM_PREFIX=x"}, []string{"ECS_TASK_ID=custom", "LOG_STREAM_PREFIX=app"}),
+		)
+	})
+}
+
+func TestLookPath(t *testing.T) {
+	writeExecutable := func(t *testing.T, dir, name string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+
+		return path
+	}
+
+	system, custom := t.TempDir(), t.TempDir()
+
+	writeExecutable(t, system, "hello")
+	expected := writeExecutable(t, custom, "hello")
+
+	t.Setenv("PATH", system)
+
+	t.Run("resolves command against PATH of environ", func(t *testing.T) {
+		path, err := lookPath("hello", []string{"PATH=" + custom})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, expected, path)
+	})
+
+	t.Run("falls back to current PATH when environ has none", func(t *testing.T) {
+		path, err := lookPath("hello", []string{"HOME=/root"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, filepath.Join(system, "hello"), path)
+	})
+
+	t.Run("skips non-executable files", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(filepath.Join(custom, "readme"), []byte("hi"), 0o644))
+
+		_, err := lookPath("readme", []string{"PATH=" + custom})
+
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+	})
+
+	t.Run("fails when command is not found", func(t *testing.T) {
+		_, err := lookPath("nonexistent-command", []string{"PATH=" + custom})
+
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+		assert.ErrorContains(t, err, "(PATH="+custom+")")
+	})
+
+	t.Run("tells when PATH is empty", func(t *testing.T) {
+		_, err := lookPath("hello", []string{"PATH="})
+
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+		assert.ErrorContains(t, err, "(PATH is empty)")
+
+		t.Setenv("PATH", "")
+
+		_, err = lookPath("hello", []string{"HOME=/root"})
+
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+		assert.ErrorContains(t, err, "(PATH is empty)")
+	})
+
+	t.Run("refuses commands found in relative PATH entries", func(t *testing.T) {
+		t.Chdir(custom)
+
+		_, err := lookPath("hello", []string{"PATH=:/nonexistent"})
+
+		assert.ErrorIs(t, err, exec.ErrDot)
+
+		_, err = lookPath("hello", []string{"PATH=.:" + system})
+
+		assert.ErrorIs(t, err, exec.ErrDot)
+	})
+}
+
+func TestResolveExecArgs(t *testing.T) {
+	oldArgsFile := execArgsFile
+
+	t.Cleanup(func() { execArgsFile = oldArgsFile })
+
+	writeArgsFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "args")
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+
+		return path
+	}
+
+	t.Run("returns args intact without --args-file", func(t *testing.T) {
+		execArgsFile = ""
+
+		args, err := resolveExecArgs([]string{"fluent-bit", "-c", "/fluent-bit/etc/fluent-bit.conf"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []string{"fluent-bit", "-c", "/fluent-bit/etc/fluent-bit.conf"}, args)
+	})
+
+	t.Run("appends args read from file one per line", func(t *testing.T) {
+		execArgsFile = writeArgsFile(t, "# config\n-c\r\n/fluent-bit/etc/with spaces.conf\n\n--dry-run\n")
+
+		args, err := resolveExecArgs([]string{"fluent-bit"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []string{"fluent-bit", "-c", "/fluent-bit/etc/with spaces.conf", "--dry-run"}, args)
+	})
+
+	t.Run("takes command from file when none is given", func(t *testing.T) {
+		execArgsFile = writeArgsFile(t, "fluent-bit\n-q")
+
+		args, err := resolveExecArgs(nil)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []string{"fluent-bit", "-q"}, args)
+	})
+
+	t.Run("fails when there's no command at all", func(t *testing.T) {
+		execArgsFile = writeArgsFile(t, "# nothing here\n")
+
+		_, err := resolveExecArgs(nil)
+
+		assert.ErrorContains(t, err, "no command given")
+	})
+
+	t.Run("fails when file can't be read", func(t *testing.T) {
+		execArgsFile = filepath.Join(t.TempDir(), "missing")
+
+		_, err := resolveExecArgs([]string{"fluent-bit"})
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("parses flags up to the command only", func(t *testing.T) {
+		flag := execCmd.Flags().Lookup("args-file")
+
+		t.Cleanup(func() {
+			flag.Changed = false
+			execArgsFile = ""
+		})
+
+		assert.Nil(t, execCmd.ParseFlags([]string{"--args-file", "/etc/args", "fluent-bit", "--args-file", "/tmp/args"}))
+		assert.Equal(t, "/etc/args", execArgsFile)
+		assert.Equal(t, []string{"fluent-bit", "--args-file", "/tmp/args"}, execCmd.Flags().Args())
+
+		assert.Nil(t, execCmd.ParseFlags([]string{"--args-file", "/etc/args", "--", "--args-file"}))
+		assert.Equal(t, []string{"--args-file"}, execCmd.Flags().Args())
+	})
+}
+
+func TestShellArgs(t *testing.T) {
+	t.Run("wraps args with sh -c", func(t *testing.T) {
+		assert.Equal(t, []string{"/bin/sh", "-c", "fluent-bit -c /fluent-bit/etc/fluent-bit.yml | tee /tmp/log"},
+			shellArgs([]string{"fluent-bit -c /fluent-bit/etc/fluent-bit.yml | tee /tmp/log"}))
+	})
+
+	t.Run("joins args with spaces verbatim", func(t *testing.T) {
+		assert.Equal(t, []string{"/bin/sh", "-c", `echo "$ECS_TASK_ID" 'a b'`},
+			shellArgs([]string{"echo", `"$ECS_TASK_ID"`, "'a b'"}))
+	})
+
+	t.Run("runs snippet with the shell", func(t *testing.T) {
+		argv := shellArgs([]string{"echo", "$ECS_TASK_ID", "|", "tr a-z A-Z"})
+
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Env = []string{"ECS_TASK_ID=deadbeef"}
+
+		output, err := cmd.Output()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "DEADBEEF\n", string(output))
+	})
+}
+
+func TestValidateSetFlags(t *testing.T) {
+	t.Run("accepts KEY=VALUE pairs", func(t *testing.T) {
+		assert.Nil(t, validateSetFlags([]string{"LOG_STREAM_PREFIX=app", "EMPTY=", "WITH_EQUALS=a=b"}, false))
+	})
+
+	t.Run("rejects malformed pairs", func(t *testing.T) {
+		for _, v := range []string{"LOG_STREAM_PREFIX", "=app", "1KEY=app", "MY-KEY=app"} {
+			assert.NotNil(t, validateSetFlags([]string{v}, false), "expected an error for %q", v)
+		}
+	})
+
+	t.Run("rejects managed keys unless forced", func(t *testing.T) {
+		assert.ErrorContains(t, validateSetFlags([]string{"ECS_TASK_ID=custom"}, false), "--force")
+		assert.Nil(t, validateSetFlags([]string{"ECS_TASK_ID=custom"}, true))
+	})
+
+	t.Run("rejects prefixed managed keys unless forced", func(t *testing.T) {
+		oldPrefix := envPrefix
+		t.Cleanup(func() { envPrefix = oldPrefix })
+
+		envPrefix = "MYAPP_"
+
+		assert.NotNil(t, validateSetFlags([]string{"MYAPP_ECS_TASK_ID=custom"}, false), "expected an error")
+		assert.Nil(t, validateSetFlags([]string{"MYAPP_ECS_TASK_ID=custom"}, true))
+	})
+}
+
+func TestExecCmdRunE(t *testing.T) {
+	dryRun := func(t *testing.T, strict bool, args ...string) (string, error) {
+		t.Helper()
+
+		oldDryRun, oldStrict := execDryRun, execStrict
+		t.Cleanup(func() { execDryRun, execStrict = oldDryRun, oldStrict })
+
+		execDryRun, execStrict = true, strict
+
+		var buf bytes.Buffer
+
+		execCmd.SetOut(&buf)
+		execCmd.SetContext(context.Background())
+		t.Cleanup(func() { execCmd.SetOut(nil) })
+
+		if len(args) == 0 {
+			args = []string{"true"}
+		}
+
+		err := execCmdRunE(execCmd, args)
+
+		return buf.String(), err
+	}
+
+	t.Run("when ECS_CONTAINER_METADATA_URI_V4 is not set", func(t *testing.T) {
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+		t.Run("proceeds without metadata", func(t *testing.T) {
+			output, err := dryRun(t, false)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Contains(t, output, "ECS_TASK_ARN=\n")
+		})
+
+		t.Run("fails in strict mode", func(t *testing.T) {
+			output, err := dryRun(t, true)
+
+			assert.NotNil(t, err, "expected an error")
+			assert.Empty(t, output)
+		})
+
+		t.Run("prints managed variables only", func(t *testing.T) {
+			t.Setenv("UNMANAGED", "unmanaged-value")
+
+			output, err := dryRun(t, false)
+
+			assert.Nil(t, err, "expected no error")
+			assert.NotContains(t, output, "UNMANAGED=")
+			assert.Equal(t, len(ecsmeta.EnvKeys), strings.Count(output, "\n"))
+		})
+
+		t.Run("prints JSON object with --format json", func(t *testing.T) {
+			oldFormat := execFormat
+			t.Cleanup(func() { execFormat = oldFormat })
+
+			execFormat = "json"
+
+			output, err := dryRun(t, false)
+
+			var object map[string]string
+
+			assert.Nil(t, err, "expected no error")
+			assert.Nil(t, json.Unmarshal([]byte(output), &object))
+			assert.Len(t, object, len(ecsmeta.EnvKeys))
+			assert.Contains(t, object, "ECS_TASK_ARN")
+		})
+	})
+
+	t.Run("when ECS_CONTAINER_METADATA_URI_V4 is unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		oldRetries := metadataRetries
+		t.Cleanup(func() { metadataRetries = oldRetries })
+
+		metadataRetries = 0
+
+		t.Run("fails in strict mode", func(t *testing.T) {
+			output, err := dryRun(t, true)
+
+			assert.NotNil(t, err, "expected an error")
+			assert.Empty(t, output)
+		})
+	})
+
+	t.Run("when interrupted while retrieving metadata", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+
+		t.Cleanup(server.Close)
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		oldDryRun := execDryRun
+		t.Cleanup(func() { execDryRun = oldDryRun })
+
+		execDryRun = true
+
+		execCmd.SetContext(ctx)
+		t.Cleanup(func() { execCmd.SetContext(context.Background()) })
+
+		err := execCmdRunE(execCmd, []string{"true"})
+
+		assert.ErrorIs(t, err, errExecInterrupted)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("with --startup-timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+
+		t.Cleanup(server.Close)
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+
+		oldStartupTimeout := execStartupTimeout
+		t.Cleanup(func() { execStartupTimeout = oldStartupTimeout })
+
+		execStartupTimeout = 20 * time.Millisecond
+
+		t.Run("proceeds without metadata when exceeded", func(t *testing.T) {
+			output, err := dryRun(t, false)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Contains(t, output, "ECS_TASK_ARN=\n")
+		})
+
+		t.Run("fails in strict mode when exceeded", func(t *testing.T) {
+			output, err := dryRun(t, true)
+
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.NotErrorIs(t, err, errExecInterrupted)
+			assert.Empty(t, output)
+		})
+	})
+
+	t.Run("with --no-metadata", func(t *testing.T) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+
+		t.Cleanup(server.Close)
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL)
+		t.Setenv("ECS_TASK_ARN", "stale-value")
+
+		oldNoMetadata := execNoMetadata
+		t.Cleanup(func() { execNoMetadata = oldNoMetadata })
+
+		execNoMetadata = true
+
+		t.Run("does not retrieve metadata", func(t *testing.T) {
+			output, err := dryRun(t, false)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Empty(t, output)
+			assert.Equal(t, int32(0), calls.Load())
+		})
+
+		t.Run("strips managed variables from the environment", func(t *testing.T) {
+			metadata, err := resolveExecMetadata(context.Background())
+
+			assert.Nil(t, err, "expected no error")
+			assert.Nil(t, metadata, "expected metadata to be nil")
+			assert.NotContains(t, execEnviron(metadata), "ECS_TASK_ARN=stale-value")
+		})
+	})
+
+	t.Run("with --skip-lookpath", func(t *testing.T) {
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+		t.Run("fails to find missing command by default", func(t *testing.T) {
+			_, err := dryRun(t, false, "nonexistent-command")
+
+			assert.ErrorIs(t, err, exec.ErrNotFound)
+		})
+
+		t.Run("does not look command up", func(t *testing.T) {
+			oldSkipLookPath := execSkipLookPath
+			t.Cleanup(func() { execSkipLookPath = oldSkipLookPath })
+
+			execSkipLookPath = true
+
+			_, err := dryRun(t, false, "nonexistent-command")
+
+			assert.Nil(t, err, "expected no error")
+		})
+	})
+
+	t.Run("with --set PATH", func(t *testing.T) {
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+		t.Setenv("PATH", t.TempDir())
+
+		dir := t.TempDir()
+
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "hello"), []byte("#!/bin/sh\n"), 0o755))
+
+		oldSet := execSet
+		t.Cleanup(func() { execSet = oldSet })
+
+		t.Run("fails to find command missing from current PATH", func(t *testing.T) {
+			_, err := dryRun(t, false, "hello")
+
+			assert.ErrorIs(t, err, exec.ErrNotFound)
+		})
+
+		t.Run("finds command in the injected PATH", func(t *testing.T) {
+			execSet = []string{"PATH=" + dir}
+
+			_, err := dryRun(t, false, "hello")
+
+			assert.Nil(t, err, "expected no error")
+		})
+	})
+
+	t.Run("with --chdir", func(t *testing.T) {
+		t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+		t.Chdir(".")
+
+		setChdir := func(t *testing.T, dir string) {
+			oldChdir := execChdir
+			t.Cleanup(func() { execChdir = oldChdir })
+
+			execChdir = dir
+		}
+
+		t.Run("resolves relative command from the given directory", func(t *testing.T) {
+			dir := t.TempDir()
+
+			assert.Nil(t, os.WriteFile(dir+"/hello.sh", []byte("#!/bin/sh\n"), 0o755))
+
+			setChdir(t, dir)
+
+			_, err := dryRun(t, false, "./hello.sh")
+
+			assert.Nil(t, err, "expected no error")
+
+			cwd, _ := os.Getwd()
+			assert.Equal(t, dir, cwd)
+		})
+
+		t.Run("fails when directory does not exist", func(t *testing.T) {
+			setChdir(t, t.TempDir()+"/nonexistent")
+
+			output, err := dryRun(t, false)
+
+			assert.ErrorIs(t, err, os.ErrNotExist)
+			assert.Empty(t, output)
+		})
+	})
 }
@@ -22,15 +22,75 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
-var healthEndpoint = "http://localhost:2020/api/v1/health"
+const (
+	versionPath = "/"
+	healthPath  = "/api/v1/health"
+	uptimePath  = "/api/v1/uptime"
+	storagePath = "/api/v1/storage"
+)
+
+// Returned when Fluent-Bit doesn't serve the requested HTTP API endpoint, e.g.
+// because it's too old.
+var errFluentBitEndpointNotFound = errors.New("Fluent-Bit endpoint not found")
+
+// Thresholds of `--full` health check, unless given with `--min-uptime` and
+// `--max-pending-chunks`.
+const (
+	healthFullMinUptime  = 5 * time.Second
+	healthFullMaxPending = 128
+)
+
+// Exit code of the health command when Fluent-Bit is not healthy, be it due to
+// a transport error or a non-OK response. ECS treats any non-zero as unhealthy.
+const healthExitUnhealthy = 1
+
+var (
+	healthEndpoints    []string
+	healthMode         = "all"
+	healthHost         = "127.0.0.1"
+	healthPort         = 2020
+	healthWait         bool
+	healthWaitTimeout  = 60 * time.Second
+	healthWaitInterval = time.Second
+	healthTimeout      = 5 * time.Second
+	healthRetries      int
+	healthRetryDelay   = 200 * time.Millisecond
+	healthJSON         bool
+	healthMinUptime    time.Duration
+	healthMaxPending   int64
+	healthCheckOutputs bool
+	healthPidFile      string
+	healthRepeat       bool
+	healthFull         bool
+	healthInterval     = 10 * time.Second
+	healthExpectStatus = "200"
+	healthAccept2xx    bool
+	healthMinVersion   string
+	healthMetricsState = filepath.Join(os.TempDir(), "fluent-bit-for-ecs-metrics.json")
+)
 
 // healthCmd represents the health command
 var healthCmd = &cobra.Command{
@@ -40,8 +100,113 @@ var healthCmd = &cobra.Command{
 	RunE:  healthCmdRunE,
 }
 
-func fetchHealthStatus() (string, error) {
-	res, err := http.DefaultClient.Get(healthEndpoint)
+// Returns URLs of the Fluent-Bit health endpoints. Explicitly given
+// `--endpoint` values win, otherwise the URL is built from `--host` and
+// `--port`. IPv6 host can be given with or without brackets.
+//
+// Host defaults to 127.0.0.1 rather than localhost, which might resolve to
+// IPv6 address Fluent-Bit doesn't listen on.
+func resolveHealthEndpoints() ([]string, error) {
+	if len(healthEndpoints) == 0 {
+		host := strings.TrimSuffix(strings.TrimPrefix(healthHost, "["), "]")
+
+		u := url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(host, strconv.Itoa(healthPort)),
+			Path:   healthPath,
+		}
+
+		return []string{u.String()}, nil
+	}
+
+	endpoints := make([]string, len(healthEndpoints))
+
+	for i, endpoint := range healthEndpoints {
+		u, err := url.Parse(endpoint)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid health endpoint %q: %w", endpoint, err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid health endpoint %q: scheme must be http or https", endpoint)
+		}
+
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid health endpoint %q: missing host", endpoint)
+		}
+
+		endpoints[i] = u.String()
+	}
+
+	return endpoints, nil
+}
+
+func validateHealthMode(mode string) error {
+	if mode != "all" && mode != "any" {
+		return fmt.Errorf("invalid --mode %q (expected all or any)", mode)
+	}
+
+	return nil
+}
+
+// Inclusive range of HTTP status codes.
+type statusRange struct {
+	min, max int
+}
+
+// Status codes of the health endpoint response considered healthy, parsed from
+// `--expect-status`.
+var healthAcceptedStatuses = []statusRange{{http.StatusOK, http.StatusOK}}
+
+// Parses comma-separated list of HTTP status codes (e.g. 200), ranges (e.g.
+// 200-204), or classes (e.g. 2xx).
+func parseStatusRanges(s string) ([]statusRange, error) {
+	var ranges []statusRange
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+
+		var r statusRange
+		var err error
+
+		if class, ok := strings.CutSuffix(part, "xx"); ok && len(class) == 1 {
+			r.min, err = strconv.Atoi(class + "00")
+			r.max = r.min + 99
+		} else if lo, hi, ok := strings.Cut(part, "-"); ok {
+			r.min, err = strconv.Atoi(lo)
+
+			if err == nil {
+				r.max, err = strconv.Atoi(hi)
+			}
+		} else {
+			r.min, err = strconv.Atoi(part)
+			r.max = r.min
+		}
+
+		if err != nil || r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, fmt.Errorf("invalid HTTP status %q (expected e.g. 200, 200-204 or 2xx)", part)
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// Returns whether `code` falls in any of the `ranges`.
+func statusInRanges(code int, ranges []statusRange) bool {
+	for _, r := range ranges {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fetchHealthStatus(client *http.Client, endpoint string) (string, error) {
+	res, err := client.Get(endpoint)
 
 	if err != nil {
 		return "UNHEALTHY", err
@@ -51,21 +216,604 @@ func fetchHealthStatus() (string, error) {
 
 	slog.Debug("GET health", "status", res.Status)
 
+	if !statusInRanges(res.StatusCode, healthAcceptedStatuses) {
+		return "UNHEALTHY", fmt.Errorf("non-OK status from health endpoint: %s", res.Status)
+	}
+
+	return "HEALTHY", nil
+}
+
+// Returns the URL of another Fluent-Bit HTTP API endpoint, served next to the
+// health endpoint.
+func siblingEndpoint(endpoint, path string) (string, error) {
+	u, err := url.Parse(endpoint)
+
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = path
+	u.RawQuery = ""
+
+	return u.String(), nil
+}
+
+// Fetches and decodes JSON document from the Fluent-Bit HTTP API endpoint.
+func fetchFluentBitDocument(client *http.Client, endpoint string, v any) error {
+	res, err := client.Get(endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	slog.Debug("GET "+endpoint, "status", res.Status)
+
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errFluentBitEndpointNotFound, endpoint)
+	}
+
 	if res.StatusCode != http.StatusOK {
-		return "HEALTHY", errors.New("non-OK status from uptime endpoint")
+		return fmt.Errorf("non-OK status from %s: %s", endpoint, res.Status)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("malformed response from %s: %w", endpoint, err)
 	}
 
-	return "UNHEALTHY", nil
+	return nil
 }
 
-func healthCmdRunE(cmd *cobra.Command, args []string) error {
-	status, err := fetchHealthStatus()
+// Returns how long Fluent-Bit has been up, as reported by the uptime endpoint.
+func fetchUptime(client *http.Client, endpoint string) (time.Duration, error) {
+	var uptime struct {
+		UptimeSec *int64 `json:"uptime_sec"`
+	}
+
+	if err := fetchFluentBitDocument(client, endpoint, &uptime); err != nil {
+		return 0, err
+	}
+
+	if uptime.UptimeSec == nil {
+		return 0, fmt.Errorf("malformed response from %s: missing uptime_sec", endpoint)
+	}
+
+	return time.Duration(*uptime.UptimeSec) * time.Second, nil
+}
+
+// Returns the total number of chunks buffered by Fluent-Bit, as reported by
+// the storage endpoint. Requires `storage.metrics` to be enabled.
+func fetchPendingChunks(client *http.Client, endpoint string) (int64, error) {
+	var storage struct {
+		StorageLayer *struct {
+			Chunks struct {
+				TotalChunks int64 `json:"total_chunks"`
+			} `json:"chunks"`
+		} `json:"storage_layer"`
+	}
+
+	if err := fetchFluentBitDocument(client, endpoint, &storage); err != nil {
+		return 0, err
+	}
+
+	if storage.StorageLayer == nil {
+		return 0, fmt.Errorf("malformed response from %s: missing storage_layer (is storage.metrics enabled?)", endpoint)
+	}
+
+	return storage.StorageLayer.Chunks.TotalChunks, nil
+}
+
+// Version of Fluent-Bit as major, minor and patch numbers.
+type fluentBitVersion [3]int
+
+func (v fluentBitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Parses version like `3.0.4`, `v3.0` or `3.1.0-rc1`. Missing minor and patch
+// numbers are zeroes, pre-release and build suffixes are ignored.
+func parseFluentBitVersion(s string) (fluentBitVersion, error) {
+	var v fluentBitVersion
+
+	core, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), "v"), "-")
+	core, _, _ = strings.Cut(core, "+")
+	parts := strings.Split(core, ".")
+
+	if len(parts) > len(v) {
+		return v, fmt.Errorf("malformed version %q", s)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("malformed version %q", s)
+		}
+
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+// Returns version of Fluent-Bit, as reported by the root endpoint of its API.
+func fetchFluentBitVersion(client *http.Client, endpoint string) (string, error) {
+	var banner struct {
+		FluentBit struct {
+			Version string `json:"version"`
+		} `json:"fluent-bit"`
+	}
+
+	if err := fetchFluentBitDocument(client, endpoint, &banner); err != nil {
+		return "", err
+	}
+
+	return banner.FluentBit.Version, nil
+}
+
+// Fails if Fluent-Bit is older than `minVersion`, e.g. because of accidental
+// image rollback. Passes with a warning if the version can't be determined.
+func checkMinVersion(client *http.Client, endpoint string, minVersion fluentBitVersion) error {
+	reported, err := fetchFluentBitVersion(client, endpoint)
+
+	if errors.Is(err, errFluentBitEndpointNotFound) {
+		slog.Warn("Fluent-Bit doesn't expose its version, skipping check", "endpoint", endpoint)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	version, err := parseFluentBitVersion(reported)
+
+	if err != nil {
+		slog.Warn("Can't parse Fluent-Bit version, skipping check", "version", reported, "error", err)
+		return nil
+	}
+
+	if slices.Compare(version[:], minVersion[:]) < 0 {
+		return fmt.Errorf("Fluent-Bit %s is older than %s", reported, minVersion)
+	}
+
+	return nil
+}
+
+// Checks whether the process with PID read from `pidfile` is alive, by sending
+// it signal 0. A process owned by another user is alive as well.
+func checkProcessAlive(pidfile string) error {
+	data, err := os.ReadFile(pidfile)
+
+	if err != nil {
+		return fmt.Errorf("can't read pidfile: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("invalid PID in %s: %q", pidfile, strings.TrimSpace(string(data)))
+	}
+
+	if err := unix.Kill(pid, 0); err != nil && !errors.Is(err, unix.EPERM) {
+		return fmt.Errorf("process %d is not alive: %w", pid, err)
+	}
+
+	return nil
+}
+
+// Returns HEALTHY if the health endpoint reports so, and all of the optional
+// checks enabled by flags pass as well.
+func checkHealth(client *http.Client, endpoint string) (string, error) {
+	status, err := fetchHealthStatus(client, endpoint)
+
+	if err != nil {
+		return status, err
+	}
+
+	// HTTP server might outlive the pipeline, or be served by a stale process.
+
+	if healthPidFile != "" {
+		if err := checkProcessAlive(healthPidFile); err != nil {
+			return "UNHEALTHY", err
+		}
+	}
+
+	if healthMinUptime > 0 {
+		uptimeEndpoint, err := siblingEndpoint(endpoint, uptimePath)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		uptime, err := fetchUptime(client, uptimeEndpoint)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		if uptime < healthMinUptime {
+			return "UNHEALTHY", fmt.Errorf("uptime %s is below %s", uptime, healthMinUptime)
+		}
+	}
+
+	if healthMaxPending > 0 {
+		storageEndpoint, err := siblingEndpoint(endpoint, storagePath)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		pending, err := fetchPendingChunks(client, storageEndpoint)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		if pending > healthMaxPending {
+			return "UNHEALTHY", fmt.Errorf("%d pending chunks exceed %d", pending, healthMaxPending)
+		}
+	}
+
+	if healthMinVersion != "" {
+		minVersion, err := parseFluentBitVersion(healthMinVersion)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		versionEndpoint, err := siblingEndpoint(endpoint, versionPath)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		if err := checkMinVersion(client, versionEndpoint, minVersion); err != nil {
+			return "UNHEALTHY", err
+		}
+	}
+
+	if healthCheckOutputs {
+		metricsEndpoint, err := siblingEndpoint(endpoint, metricsPath)
+
+		if err != nil {
+			return "UNHEALTHY", err
+		}
+
+		if err := checkOutputErrors(client, metricsEndpoint, healthMetricsStatePath(endpoint)); err != nil {
+			return "UNHEALTHY", err
+		}
+	}
+
+	return status, nil
+}
+
+// Checks health status, uptime and storage backlog of Fluent-Bit in a single
+// pass. Returns a one-line summary, prefixed with HEALTHY or UNHEALTHY.
+func checkFullHealth(client *http.Client, endpoint string, minUptime time.Duration, maxPending int64) (string, error) {
+	if _, err := fetchHealthStatus(client, endpoint); err != nil {
+		return "UNHEALTHY: " + err.Error(), err
+	}
+
+	uptimeEndpoint, err := siblingEndpoint(endpoint, uptimePath)
+
+	if err != nil {
+		return "UNHEALTHY: " + err.Error(), err
+	}
+
+	storageEndpoint, err := siblingEndpoint(endpoint, storagePath)
+
+	if err != nil {
+		return "UNHEALTHY: " + err.Error(), err
+	}
+
+	uptime, err := fetchUptime(client, uptimeEndpoint)
+
+	if err != nil {
+		return "UNHEALTHY: " + err.Error(), err
+	}
+
+	pending, err := fetchPendingChunks(client, storageEndpoint)
+
+	if err != nil {
+		return "UNHEALTHY: " + err.Error(), err
+	}
+
+	var errs []error
+
+	if uptime < minUptime {
+		errs = append(errs, fmt.Errorf("uptime %s is below %s", uptime, minUptime))
+	}
+
+	if pending > maxPending {
+		errs = append(errs, fmt.Errorf("%d pending chunks exceed %d", pending, maxPending))
+	}
+
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		return "UNHEALTHY: " + strings.ReplaceAll(err.Error(), "\n", ", "), err
+	}
+
+	return fmt.Sprintf("HEALTHY: up for %s, %d pending chunks", uptime, pending), nil
+}
+
+// Returns the metrics state file of the endpoint. When several endpoints are
+// checked, each of them gets its own state file, suffixed with endpoint hash.
+func healthMetricsStatePath(endpoint string) string {
+	if len(healthEndpoints) <= 1 {
+		return healthMetricsState
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(endpoint))
+
+	ext := filepath.Ext(healthMetricsState)
+
+	return fmt.Sprintf("%s.%08x%s", strings.TrimSuffix(healthMetricsState, ext), hash.Sum32(), ext)
+}
+
+// Checks health of every endpoint. With `all` mode, Fluent-Bit is HEALTHY when
+// all of the endpoints are, and with `any` mode when at least one of them is.
+// Results of individual endpoints are reported only when there's more than
+// one endpoint.
+func checkEndpointsHealth(client *http.Client, endpoints []string, mode string) (healthReport, error) {
+	if len(endpoints) == 1 {
+		started := time.Now()
+		status, err := checkHealth(client, endpoints[0])
+
+		return newHealthReport(status, endpoints[0], time.Since(started), err), err
+	}
+
+	var errs []error
+
+	started := time.Now()
+	results := make([]healthReport, len(endpoints))
+
+	for i, endpoint := range endpoints {
+		endpointStarted := time.Now()
+		status, err := checkHealth(client, endpoint)
+
+		results[i] = newHealthReport(status, endpoint, time.Since(endpointStarted), err)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+		}
+	}
+
+	var err error
+
+	if len(errs) == len(endpoints) || (mode == "all" && len(errs) > 0) {
+		err = errors.Join(errs...)
+	}
+
+	status := "HEALTHY"
+
+	if err != nil {
+		status = "UNHEALTHY"
+	}
+
+	report := newHealthReport(status, "", time.Since(started), err)
+	report.Endpoints = results
+
+	return report, err
+}
+
+// Polls health endpoints every `interval` until they report HEALTHY or the
+// `timeout` elapses. Requests never outlive the `timeout`, even if the client
+// allows them to take longer. Returns the last seen report and error.
+func waitForHealthStatus(client *http.Client, endpoints []string, mode string, timeout, interval time.Duration) (healthReport, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		pollClient := *client
+
+		if remaining := time.Until(deadline); pollClient.Timeout <= 0 || remaining < pollClient.Timeout {
+			pollClient.Timeout = max(remaining, time.Millisecond)
+		}
+
+		report, err := checkEndpointsHealth(&pollClient, endpoints, mode)
+
+		if err == nil {
+			return report, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			err = fmt.Errorf("not healthy after %s: %w", timeout, err)
+			report.Error = err.Error()
 
-	fmt.Println(status)
+			return report, err
+		}
+
+		slog.Debug("Fluent-Bit is not healthy yet", "error", err, "retry_in", interval)
+
+		time.Sleep(interval)
+	}
+}
+
+// Checks health of endpoints, retrying up to `retries` times after `interval`
+// when they're not healthy, to tolerate a single dropped request. Returns the
+// first HEALTHY report, or the last seen report and error.
+func retryHealthStatus(client *http.Client, endpoints []string, mode string, retries int, interval time.Duration) (healthReport, error) {
+	for attempt := 0; ; attempt++ {
+		report, err := checkEndpointsHealth(client, endpoints, mode)
+
+		if err == nil || attempt >= retries {
+			return report, err
+		}
+
+		slog.Debug("Fluent-Bit is not healthy, retrying", "attempt", attempt+1, "error", err, "retry_in", interval)
+
+		time.Sleep(interval)
+	}
+}
+
+// Checks health of endpoints every `interval`, writing each report to `w`,
+// until `ctx` is done.
+func repeatHealthChecks(ctx context.Context, w io.Writer, client *http.Client, endpoints []string, mode string, interval time.Duration, asJSON bool) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := retryHealthStatus(client, endpoints, mode, healthRetries, healthRetryDelay)
+
+		if err != nil {
+			slog.Warn("Fluent-Bit is not healthy", "endpoints", endpoints, "error", err)
+		}
+
+		if err := writeHealthReport(w, report, asJSON); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Debug("Stopping health checks", "reason", ctx.Err())
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type healthReport struct {
+	Status    string         `json:"status"`
+	Endpoint  string         `json:"endpoint,omitempty"`
+	LatencyMs int64          `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Endpoints []healthReport `json:"endpoints,omitempty"`
+}
+
+func newHealthReport(status, endpoint string, latency time.Duration, err error) healthReport {
+	report := healthReport{Status: status, Endpoint: endpoint, LatencyMs: latency.Milliseconds()}
+
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return report
+}
+
+// Writes health report to `w`, either as a bare status or as a JSON object.
+func writeHealthReport(w io.Writer, report healthReport, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(report)
+	}
+
+	_, err := fmt.Fprintln(w, report.Status)
 
 	return err
 }
 
+func healthCmdRunE(cmd *cobra.Command, args []string) error {
+	if err := validateHealthMode(healthMode); err != nil {
+		return err
+	}
+
+	expectStatus := healthExpectStatus
+
+	if healthAccept2xx {
+		expectStatus = "2xx"
+	}
+
+	statuses, err := parseStatusRanges(expectStatus)
+
+	if err != nil {
+		return fmt.Errorf("invalid --expect-status: %w", err)
+	}
+
+	healthAcceptedStatuses = statuses
+
+	if healthMinVersion != "" {
+		if _, err := parseFluentBitVersion(healthMinVersion); err != nil {
+			return fmt.Errorf("invalid --min-version: %w", err)
+		}
+	}
+
+	endpoints, err := resolveHealthEndpoints()
+
+	if err != nil {
+		return err
+	}
+
+	var report healthReport
+
+	client := newHTTPClient(withTimeout(healthTimeout))
+
+	if healthFull {
+		if len(endpoints) > 1 {
+			return errors.New("--full supports a single endpoint only")
+		}
+
+		summary, err := checkFullHealth(client, endpoints[0], cmp.Or(healthMinUptime, healthFullMinUptime), cmp.Or(healthMaxPending, healthFullMaxPending))
+
+		if _, err := fmt.Fprintln(cmd.OutOrStdout(), summary); err != nil {
+			return err
+		}
+
+		if err != nil {
+			return &exitError{code: healthExitUnhealthy, err: err}
+		}
+
+		return nil
+	}
+
+	if healthRepeat {
+		ctx, stop := signal.NotifyContext(cmd.Context(), unix.SIGTERM, unix.SIGINT)
+		defer stop()
+
+		return repeatHealthChecks(ctx, cmd.OutOrStdout(), client, endpoints, healthMode, healthInterval, healthJSON)
+	}
+
+	if healthWait {
+		report, err = waitForHealthStatus(client, endpoints, healthMode, healthWaitTimeout, healthWaitInterval)
+	} else {
+		report, err = retryHealthStatus(client, endpoints, healthMode, healthRetries, healthRetryDelay)
+	}
+
+	if err := writeHealthReport(cmd.OutOrStdout(), report, healthJSON); err != nil {
+		return err
+	}
+
+	if report.Status != "HEALTHY" {
+		slog.Error("Fluent-Bit is not healthy", "endpoints", endpoints, "error", err)
+		return &exitError{code: healthExitUnhealthy, err: err}
+	}
+
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(healthCmd)
+
+	healthCmd.Flags().StringArrayVar(&healthEndpoints, "endpoint", nil, "Fluent-Bit health endpoint URL (overrides --host and --port), can be repeated")
+	healthCmd.Flags().StringVar(&healthMode, "mode", healthMode, "With several --endpoint, require all or any of them to be healthy")
+	healthCmd.Flags().StringVar(&healthHost, "host", healthHost, "Fluent-Bit HTTP server host or IP address, IPv4 or IPv6 (e.g. ::1)")
+	healthCmd.Flags().IntVar(&healthPort, "port", healthPort, "Fluent-Bit HTTP server port")
+	healthCmd.Flags().StringVar(&healthExpectStatus, "expect-status", healthExpectStatus, "Comma-separated HTTP statuses of health endpoint considered healthy, e.g. 200, 200-204 or 2xx")
+	healthCmd.Flags().BoolVar(&healthAccept2xx, "accept-2xx", false, "Consider any 2xx status of health endpoint healthy (same as --expect-status=2xx)")
+
+	healthCmd.Flags().BoolVar(&healthWait, "wait", false, "Poll health endpoint until Fluent-Bit becomes healthy")
+	healthCmd.Flags().DurationVar(&healthWaitTimeout, "wait-timeout", healthWaitTimeout, "Maximum time to wait for Fluent-Bit to become healthy")
+	healthCmd.Flags().DurationVar(&healthWaitInterval, "wait-interval", healthWaitInterval, "Interval between health polls")
+	healthCmd.Flags().DurationVar(&healthTimeout, "timeout", healthTimeout, "Time limit of each request to Fluent-Bit HTTP API (0 for no limit)")
+
+	healthCmd.Flags().BoolVar(&healthFull, "full", false, fmt.Sprintf("Check health status, uptime (--min-uptime, %s by default) and pending chunks (--max-pending-chunks, %d by default) in one pass, printing one-line summary", healthFullMinUptime, healthFullMaxPending))
+	healthCmd.Flags().BoolVar(&healthRepeat, "repeat", false, "Keep checking health every --interval, printing each result, until terminated")
+	healthCmd.Flags().DurationVar(&healthInterval, "interval", healthInterval, "With --repeat, interval between health checks")
+
+	healthCmd.Flags().IntVar(&healthRetries, "retries", 0, "Number of times to retry the check before reporting UNHEALTHY")
+	healthCmd.Flags().DurationVar(&healthRetryDelay, "retry-interval", healthRetryDelay, "Interval between health check retries")
+
+	healthCmd.Flags().DurationVar(&healthMinUptime, "min-uptime", 0, "Report UNHEALTHY until Fluent-Bit has been up for at least this long")
+	healthCmd.Flags().Int64Var(&healthMaxPending, "max-pending-chunks", 0, "Report UNHEALTHY when Fluent-Bit buffers more chunks than this (requires storage.metrics)")
+	healthCmd.Flags().BoolVar(&healthCheckOutputs, "check-output-errors", false, "Report UNHEALTHY when output errors or failed retries increased since the previous check")
+	healthCmd.Flags().StringVar(&healthMinVersion, "min-version", "", "Report UNHEALTHY when Fluent-Bit is older than this version (e.g. 3.0.4)")
+	healthCmd.Flags().StringVar(&healthPidFile, "pidfile", "", "Report UNHEALTHY when the Fluent-Bit process with PID from the file is not alive")
+	healthCmd.Flags().StringVar(&healthMetricsState, "metrics-state-file", healthMetricsState, "File to keep output metrics snapshot between checks in")
+	healthCmd.Flags().BoolVar(&healthJSON, "json", false, "Print health status, endpoint(s) and latency as JSON")
+
+	healthCmd.MarkFlagsMutuallyExclusive("endpoint", "host")
+	healthCmd.MarkFlagsMutuallyExclusive("endpoint", "port")
+	healthCmd.MarkFlagsMutuallyExclusive("expect-status", "accept-2xx")
+	healthCmd.MarkFlagsMutuallyExclusive("wait", "retries")
+	healthCmd.MarkFlagsMutuallyExclusive("wait", "repeat")
+	healthCmd.MarkFlagsMutuallyExclusive("full", "wait", "repeat", "json")
 }
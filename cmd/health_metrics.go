@@ -0,0 +1,141 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+const metricsPath = "/api/v1/metrics"
+
+// Counters of a Fluent-Bit output, that indicate delivery failures.
+type outputCounters struct {
+	Errors        uint64 `json:"errors"`
+	RetriesFailed uint64 `json:"retries_failed"`
+}
+
+// Snapshot of output counters, keyed by output name (e.g. `cloudwatch_logs.0`).
+type metricsSnapshot map[string]outputCounters
+
+// Returns output counters, as reported by the metrics endpoint.
+func fetchMetricsSnapshot(client *http.Client, endpoint string) (metricsSnapshot, error) {
+	var metrics struct {
+		Output metricsSnapshot `json:"output"`
+	}
+
+	if err := fetchFluentBitDocument(client, endpoint, &metrics); err != nil {
+		return nil, err
+	}
+
+	return metrics.Output, nil
+}
+
+// Reads snapshot from the state file. Returns nil snapshot if there's no
+// state file yet.
+func readMetricsSnapshot(path string) (metricsSnapshot, error) {
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot metricsSnapshot
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("malformed metrics state file %s: %w", path, err)
+	}
+
+	return snapshot, nil
+}
+
+func writeMetricsSnapshot(path string, snapshot metricsSnapshot) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snapshot)
+	})
+}
+
+// Returns names of outputs whose error counters increased since `previous`
+// snapshot. Outputs unknown to the previous snapshot, and outputs whose
+// counters went down (e.g. after Fluent-Bit restart) are not reported.
+func failingOutputs(previous, current metricsSnapshot) []string {
+	var failing []string
+
+	for name, counters := range current {
+		prev, ok := previous[name]
+
+		if !ok {
+			continue
+		}
+
+		if counters.Errors > prev.Errors || counters.RetriesFailed > prev.RetriesFailed {
+			failing = append(failing, name)
+		}
+	}
+
+	slices.Sort(failing)
+
+	return failing
+}
+
+// Compares output counters with the snapshot saved in the state file by the
+// previous check, and stores current counters for the next one. When there's
+// no previous snapshot, there's nothing to compare with, so check passes.
+func checkOutputErrors(client *http.Client, endpoint, statePath string) error {
+	current, err := fetchMetricsSnapshot(client, endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	previous, err := readMetricsSnapshot(statePath)
+
+	if err != nil {
+		slog.Warn("Failed to read metrics state file, starting over", "path", statePath, "error", err)
+	}
+
+	if err := writeMetricsSnapshot(statePath, current); err != nil {
+		return fmt.Errorf("failed to write metrics state file %s: %w", statePath, err)
+	}
+
+	if previous == nil {
+		slog.Debug("No previous metrics snapshot", "path", statePath)
+		return nil
+	}
+
+	if failing := failingOutputs(previous, current); len(failing) > 0 {
+		return fmt.Errorf("output errors increased: %s", strings.Join(failing, ", "))
+	}
+
+	return nil
+}
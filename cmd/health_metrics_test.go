@@ -0,0 +1,122 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailingOutputs(t *testing.T) {
+	previous := metricsSnapshot{
+		"cloudwatch_logs.0": {Errors: 1, RetriesFailed: 1},
+		"stdout.1":          {Errors: 0, RetriesFailed: 0},
+	}
+
+	t.Run("reports outputs with increased counters", func(t *testing.T) {
+		current := metricsSnapshot{
+			"cloudwatch_logs.0": {Errors: 1, RetriesFailed: 2},
+			"stdout.1":          {Errors: 3, RetriesFailed: 0},
+		}
+
+		assert.Equal(t, []string{"cloudwatch_logs.0", "stdout.1"}, failingOutputs(previous, current))
+	})
+
+	t.Run("ignores unchanged and decreased counters", func(t *testing.T) {
+		current := metricsSnapshot{
+			"cloudwatch_logs.0": {Errors: 0, RetriesFailed: 0},
+			"stdout.1":          {Errors: 0, RetriesFailed: 0},
+		}
+
+		assert.Empty(t, failingOutputs(previous, current))
+	})
+
+	t.Run("ignores outputs unknown to previous snapshot", func(t *testing.T) {
+		current := metricsSnapshot{"s3.2": {Errors: 5, RetriesFailed: 5}}
+
+		assert.Empty(t, failingOutputs(previous, current))
+		assert.Empty(t, failingOutputs(nil, current))
+	})
+}
+
+func TestCheckOutputErrors(t *testing.T) {
+	fakeMetricsServer := func(t *testing.T, body string) string {
+		server := fakeFluentBitAPIServer(t, http.StatusOK, map[string]string{metricsPath: body})
+
+		return server.URL + metricsPath
+	}
+
+	t.Run("when there's no previous snapshot", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "metrics.json")
+		endpoint := fakeMetricsServer(t, `{"output":{"stdout.0":{"errors":5,"retries_failed":5}}}`)
+
+		assert.Nil(t, checkOutputErrors(http.DefaultClient, endpoint, statePath))
+
+		snapshot, err := readMetricsSnapshot(statePath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metricsSnapshot{"stdout.0": {Errors: 5, RetriesFailed: 5}}, snapshot)
+	})
+
+	t.Run("when counters did not change", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "metrics.json")
+		endpoint := fakeMetricsServer(t, `{"output":{"stdout.0":{"errors":5,"retries_failed":5}}}`)
+
+		assert.Nil(t, writeMetricsSnapshot(statePath, metricsSnapshot{"stdout.0": {Errors: 5, RetriesFailed: 5}}))
+		assert.Nil(t, checkOutputErrors(http.DefaultClient, endpoint, statePath))
+	})
+
+	t.Run("when counters increased", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "metrics.json")
+		endpoint := fakeMetricsServer(t, `{"output":{"stdout.0":{"errors":5,"retries_failed":6}}}`)
+
+		assert.Nil(t, writeMetricsSnapshot(statePath, metricsSnapshot{"stdout.0": {Errors: 5, RetriesFailed: 5}}))
+		assert.ErrorContains(t, checkOutputErrors(http.DefaultClient, endpoint, statePath), "stdout.0")
+
+		snapshot, _ := readMetricsSnapshot(statePath)
+
+		assert.Equal(t, metricsSnapshot{"stdout.0": {Errors: 5, RetriesFailed: 6}}, snapshot)
+	})
+
+	t.Run("when state file is malformed", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "metrics.json")
+		endpoint := fakeMetricsServer(t, `{"output":{"stdout.0":{"errors":5,"retries_failed":5}}}`)
+
+		assert.Nil(t, os.WriteFile(statePath, []byte("wazzup"), 0o600))
+		assert.Nil(t, checkOutputErrors(http.DefaultClient, endpoint, statePath))
+
+		snapshot, _ := readMetricsSnapshot(statePath)
+
+		assert.Equal(t, metricsSnapshot{"stdout.0": {Errors: 5, RetriesFailed: 5}}, snapshot)
+	})
+
+	t.Run("when state file can't be written", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "nonexistent", "metrics.json")
+		endpoint := fakeMetricsServer(t, `{"output":{}}`)
+
+		assert.ErrorIs(t, checkOutputErrors(http.DefaultClient, endpoint, statePath), os.ErrNotExist)
+	})
+}
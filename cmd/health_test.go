@@ -0,0 +1,908 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveHealthEndpoints(t *testing.T) {
+	setHealthFlags := func(t *testing.T, endpoints []string, host string, port int) {
+		t.Helper()
+
+		oldEndpoints, oldHost, oldPort := healthEndpoints, healthHost, healthPort
+
+		t.Cleanup(func() {
+			healthEndpoints, healthHost, healthPort = oldEndpoints, oldHost, oldPort
+		})
+
+		healthEndpoints, healthHost, healthPort = endpoints, host, port
+	}
+
+	t.Run("when endpoint is not set", func(t *testing.T) {
+		t.Run("returns default endpoint", func(t *testing.T) {
+			setHealthFlags(t, nil, "localhost", 2020)
+
+			endpoints, err := resolveHealthEndpoints()
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, []string{"http://localhost:2020/api/v1/health"}, endpoints)
+		})
+
+		t.Run("builds endpoint from host and port", func(t *testing.T) {
+			setHealthFlags(t, nil, "127.0.0.1", 2021)
+
+			endpoints, err := resolveHealthEndpoints()
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, []string{"http://127.0.0.1:2021/api/v1/health"}, endpoints)
+		})
+
+		t.Run("brackets IPv6 host", func(t *testing.T) {
+			for host, expected := range map[string]string{
+				"::1":          "http://[::1]:2020/api/v1/health",
+				"[::1]":        "http://[::1]:2020/api/v1/health",
+				"fd00::2":      "http://[fd00::2]:2020/api/v1/health",
+				"fe80::1%eth0": "http://[fe80::1%25eth0]:2020/api/v1/health",
+			} {
+				setHealthFlags(t, nil, host, 2020)
+
+				endpoints, err := resolveHealthEndpoints()
+
+				assert.Nil(t, err, "expected no error")
+				assert.Equal(t, []string{expected}, endpoints)
+			}
+		})
+
+		t.Run("defaults to IPv4 loopback address", func(t *testing.T) {
+			assert.Equal(t, "127.0.0.1", healthCmd.Flags().Lookup("host").DefValue)
+		})
+	})
+
+	t.Run("when endpoint is set", func(t *testing.T) {
+		t.Run("returns endpoint as is", func(t *testing.T) {
+			setHealthFlags(t, []string{"https://fluent-bit:2021/api/v1/health"}, "localhost", 2020)
+
+			endpoints, err := resolveHealthEndpoints()
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, []string{"https://fluent-bit:2021/api/v1/health"}, endpoints)
+		})
+
+		t.Run("returns multiple endpoints", func(t *testing.T) {
+			setHealthFlags(t, []string{"http://forwarder:2020/api/v1/health", "http://aggregator:2020/api/v1/health"}, "localhost", 2020)
+
+			endpoints, err := resolveHealthEndpoints()
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, []string{"http://forwarder:2020/api/v1/health", "http://aggregator:2020/api/v1/health"}, endpoints)
+		})
+
+		t.Run("returns error when endpoint is malformed", func(t *testing.T) {
+			for _, malformed := range []string{"://wazzup", "localhost:2020", "ftp://localhost:2020", "http:///api/v1/health"} {
+				setHealthFlags(t, []string{"http://localhost:2020/api/v1/health", malformed}, "localhost", 2020)
+
+				endpoints, err := resolveHealthEndpoints()
+
+				assert.NotNil(t, err, "expected an error for %q", malformed)
+				assert.Empty(t, endpoints)
+			}
+		})
+	})
+}
+
+func TestFetchHealthStatus(t *testing.T) {
+	fakeHealthServer := func(t *testing.T, statusCode int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "GET", r.Method, "HTTP verb should be GET")
+			w.WriteHeader(statusCode)
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	t.Run("when server returns OK", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusOK)
+
+		status, err := fetchHealthStatus(server.Client(), server.URL)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when server returns error", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusInternalServerError)
+
+		status, err := fetchHealthStatus(server.Client(), server.URL)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when server returns 204", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusNoContent)
+
+		status, err := fetchHealthStatus(server.Client(), server.URL)
+
+		assert.ErrorContains(t, err, "non-OK status from health endpoint: 204 No Content")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when server returns 204 and 2xx is expected", func(t *testing.T) {
+		oldStatuses := healthAcceptedStatuses
+
+		t.Cleanup(func() { healthAcceptedStatuses = oldStatuses })
+
+		healthAcceptedStatuses = []statusRange{{200, 299}}
+		server := fakeHealthServer(t, http.StatusNoContent)
+
+		status, err := fetchHealthStatus(server.Client(), server.URL)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when server is unreachable", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusOK)
+		server.Close()
+
+		status, err := fetchHealthStatus(server.Client(), server.URL)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	t.Run("parses codes, ranges and classes", func(t *testing.T) {
+		ranges, err := parseStatusRanges("200, 202-204,3XX")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []statusRange{{200, 200}, {202, 204}, {300, 399}}, ranges)
+
+		assert.True(t, statusInRanges(203, ranges))
+		assert.True(t, statusInRanges(302, ranges))
+		assert.False(t, statusInRanges(201, ranges))
+		assert.False(t, statusInRanges(500, ranges))
+	})
+
+	t.Run("fails on invalid statuses", func(t *testing.T) {
+		for _, invalid := range []string{"", "OK", "99", "600", "204-200", "2x", "20xx", "200-"} {
+			_, err := parseStatusRanges(invalid)
+
+			assert.NotNil(t, err, "expected an error for %q", invalid)
+		}
+	})
+}
+
+// Returns a fake Fluent-Bit HTTP server, responding on health endpoint with
+// the given status, and on other endpoints with the given JSON documents.
+func fakeFluentBitAPIServer(t *testing.T, healthStatus int, documents map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == healthPath {
+			w.WriteHeader(healthStatus)
+			return
+		}
+
+		body, ok := documents[r.URL.Path]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCheckHealth(t *testing.T) {
+	setMinUptime := func(t *testing.T, minUptime time.Duration) {
+		t.Helper()
+
+		old := healthMinUptime
+
+		t.Cleanup(func() { healthMinUptime = old })
+
+		healthMinUptime = minUptime
+	}
+
+	setMaxPending := func(t *testing.T, maxPending int64) {
+		t.Helper()
+
+		old := healthMaxPending
+
+		t.Cleanup(func() { healthMaxPending = old })
+
+		healthMaxPending = maxPending
+	}
+
+	fakeFluentBitServer := func(t *testing.T, healthStatus int, uptimeBody string) *httptest.Server {
+		return fakeFluentBitAPIServer(t, healthStatus, map[string]string{uptimePath: uptimeBody})
+	}
+
+	fakeStorageServer := func(t *testing.T, storageBody string) *httptest.Server {
+		return fakeFluentBitAPIServer(t, http.StatusOK, map[string]string{storagePath: storageBody})
+	}
+
+	t.Run("ignores uptime when --min-uptime is not set", func(t *testing.T) {
+		setMinUptime(t, 0)
+
+		server := fakeFluentBitServer(t, http.StatusOK, `{"uptime_sec":1}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when uptime is above --min-uptime", func(t *testing.T) {
+		setMinUptime(t, 30*time.Second)
+
+		server := fakeFluentBitServer(t, http.StatusOK, `{"uptime_sec":42,"uptime_hr":"Fluent Bit has been running:  0 day, 0 hour, 0 minute and 42 seconds"}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when uptime is below --min-uptime", func(t *testing.T) {
+		setMinUptime(t, 30*time.Second)
+
+		server := fakeFluentBitServer(t, http.StatusOK, `{"uptime_sec":5}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.ErrorContains(t, err, "uptime 5s is below 30s")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when uptime response is malformed", func(t *testing.T) {
+		setMinUptime(t, 30*time.Second)
+
+		for _, body := range []string{`wazzup`, `{}`} {
+			server := fakeFluentBitServer(t, http.StatusOK, body)
+
+			status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+			assert.NotNil(t, err, "expected an error for %q", body)
+			assert.Equal(t, "UNHEALTHY", status)
+		}
+	})
+
+	t.Run("when health endpoint reports unhealthy", func(t *testing.T) {
+		setMinUptime(t, 30*time.Second)
+
+		server := fakeFluentBitServer(t, http.StatusInternalServerError, `{"uptime_sec":42}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when pending chunks are within --max-pending-chunks", func(t *testing.T) {
+		setMaxPending(t, 10)
+
+		server := fakeStorageServer(t, `{"storage_layer":{"chunks":{"total_chunks":10,"mem_chunks":10,"fs_chunks":0}}}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when pending chunks exceed --max-pending-chunks", func(t *testing.T) {
+		setMaxPending(t, 10)
+
+		server := fakeStorageServer(t, `{"storage_layer":{"chunks":{"total_chunks":11,"mem_chunks":3,"fs_chunks":8}}}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.ErrorContains(t, err, "11 pending chunks exceed 10")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when storage metrics are disabled", func(t *testing.T) {
+		setMaxPending(t, 10)
+
+		server := fakeStorageServer(t, `{}`)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.ErrorContains(t, err, "storage.metrics")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+}
+
+func TestCheckFullHealth(t *testing.T) {
+	fakeFluentBitServer := func(t *testing.T, healthStatus int, uptimeSec, pendingChunks int) *httptest.Server {
+		return fakeFluentBitAPIServer(t, healthStatus, map[string]string{
+			uptimePath:  fmt.Sprintf(`{"uptime_sec":%d}`, uptimeSec),
+			storagePath: fmt.Sprintf(`{"storage_layer":{"chunks":{"total_chunks":%d}}}`, pendingChunks),
+		})
+	}
+
+	t.Run("when all checks pass", func(t *testing.T) {
+		server := fakeFluentBitServer(t, http.StatusOK, 60, 3)
+
+		summary, err := checkFullHealth(server.Client(), server.URL+healthPath, 30*time.Second, 10)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY: up for 1m0s, 3 pending chunks", summary)
+	})
+
+	t.Run("when health endpoint is not OK", func(t *testing.T) {
+		server := fakeFluentBitServer(t, http.StatusInternalServerError, 60, 3)
+
+		summary, err := checkFullHealth(server.Client(), server.URL+healthPath, 30*time.Second, 10)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.True(t, strings.HasPrefix(summary, "UNHEALTHY: "), "unexpected summary: %q", summary)
+	})
+
+	t.Run("when uptime and backlog are both off", func(t *testing.T) {
+		server := fakeFluentBitServer(t, http.StatusOK, 1, 11)
+
+		summary, err := checkFullHealth(server.Client(), server.URL+healthPath, 30*time.Second, 10)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY: uptime 1s is below 30s, 11 pending chunks exceed 10", summary)
+	})
+
+	t.Run("when storage metrics are disabled", func(t *testing.T) {
+		server := fakeFluentBitAPIServer(t, http.StatusOK, map[string]string{uptimePath: `{"uptime_sec":60}`, storagePath: `{}`})
+
+		summary, err := checkFullHealth(server.Client(), server.URL+healthPath, 30*time.Second, 10)
+
+		assert.ErrorContains(t, err, "is storage.metrics enabled?")
+		assert.NotContains(t, summary, "\n")
+	})
+}
+
+func TestParseFluentBitVersion(t *testing.T) {
+	t.Run("parses versions", func(t *testing.T) {
+		for s, expected := range map[string]fluentBitVersion{
+			"3.0.4":       {3, 0, 4},
+			"v3.0":        {3, 0, 0},
+			"4":           {4, 0, 0},
+			"3.1.0-rc1":   {3, 1, 0},
+			"2.2.3+build": {2, 2, 3},
+		} {
+			version, err := parseFluentBitVersion(s)
+
+			assert.Nil(t, err, "expected no error for %q", s)
+			assert.Equal(t, expected, version)
+		}
+	})
+
+	t.Run("fails on malformed versions", func(t *testing.T) {
+		for _, malformed := range []string{"", "latest", "3.0.x", "1.2.3.4", "3..1", "-1.0"} {
+			_, err := parseFluentBitVersion(malformed)
+
+			assert.NotNil(t, err, "expected an error for %q", malformed)
+		}
+	})
+}
+
+func TestCheckHealth_MinVersion(t *testing.T) {
+	old := healthMinVersion
+	t.Cleanup(func() { healthMinVersion = old })
+
+	healthMinVersion = "3.0.4"
+
+	versionServer := func(t *testing.T, version string) *httptest.Server {
+		return fakeFluentBitAPIServer(t, http.StatusOK, map[string]string{
+			versionPath: `{"fluent-bit":{"version":"` + version + `","edition":"Community","flags":[]}}`,
+		})
+	}
+
+	t.Run("when Fluent-Bit is up to date", func(t *testing.T) {
+		for _, version := range []string{"3.0.4", "3.1.0", "4.0.0"} {
+			server := versionServer(t, version)
+
+			status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+			assert.Nil(t, err, "expected no error for %s", version)
+			assert.Equal(t, "HEALTHY", status)
+		}
+	})
+
+	t.Run("when Fluent-Bit is older", func(t *testing.T) {
+		server := versionServer(t, "2.2.3")
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.ErrorContains(t, err, "Fluent-Bit 2.2.3 is older than 3.0.4")
+		assert.Equal(t, "UNHEALTHY", status)
+	})
+
+	t.Run("when Fluent-Bit version is malformed", func(t *testing.T) {
+		server := versionServer(t, "wazzup")
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+
+	t.Run("when Fluent-Bit doesn't expose version", func(t *testing.T) {
+		server := fakeFluentBitAPIServer(t, http.StatusOK, nil)
+
+		status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", status)
+	})
+}
+
+func TestCheckProcessAlive(t *testing.T) {
+	writePidFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "fluent-bit.pid")
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+
+		return path
+	}
+
+	t.Run("when process is alive", func(t *testing.T) {
+		assert.Nil(t, checkProcessAlive(writePidFile(t, strconv.Itoa(os.Getpid())+"\n")))
+	})
+
+	t.Run("when process is dead", func(t *testing.T) {
+		cmd := exec.Command("true")
+		assert.Nil(t, cmd.Run())
+
+		assert.ErrorContains(t, checkProcessAlive(writePidFile(t, strconv.Itoa(cmd.Process.Pid))), "is not alive")
+	})
+
+	t.Run("when pidfile is malformed", func(t *testing.T) {
+		assert.ErrorContains(t, checkProcessAlive(writePidFile(t, "wazzup")), "invalid PID")
+		assert.ErrorContains(t, checkProcessAlive(writePidFile(t, "0")), "invalid PID")
+	})
+
+	t.Run("when pidfile is missing", func(t *testing.T) {
+		assert.ErrorContains(t, checkProcessAlive(filepath.Join(t.TempDir(), "missing.pid")), "can't read pidfile")
+	})
+}
+
+func TestCheckHealth_PidFile(t *testing.T) {
+	old := healthPidFile
+	t.Cleanup(func() { healthPidFile = old })
+
+	cmd := exec.Command("true")
+	assert.Nil(t, cmd.Run())
+
+	healthPidFile = filepath.Join(t.TempDir(), "fluent-bit.pid")
+	assert.Nil(t, os.WriteFile(healthPidFile, []byte(strconv.Itoa(cmd.Process.Pid)), 0o644))
+
+	server := fakeFluentBitAPIServer(t, http.StatusOK, nil)
+
+	status, err := checkHealth(server.Client(), server.URL+healthPath)
+
+	assert.ErrorContains(t, err, "is not alive")
+	assert.Equal(t, "UNHEALTHY", status)
+}
+
+func TestWaitForHealthStatus(t *testing.T) {
+	fakeBootingServer := func(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server, &calls
+	}
+
+	t.Run("returns as soon as server becomes healthy", func(t *testing.T) {
+		server, calls := fakeBootingServer(t, 2)
+
+		report, err := waitForHealthStatus(server.Client(), []string{server.URL}, "all", time.Second, time.Millisecond)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", report.Status)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("returns error when timeout elapses", func(t *testing.T) {
+		server, _ := fakeBootingServer(t, 1000)
+
+		report, err := waitForHealthStatus(server.Client(), []string{server.URL}, "all", 20*time.Millisecond, 5*time.Millisecond)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", report.Status)
+		assert.Contains(t, report.Error, "not healthy after")
+	})
+
+	t.Run("does not hang on unresponsive server", func(t *testing.T) {
+		server := fakeHangingServer(t)
+		started := time.Now()
+
+		report, err := waitForHealthStatus(newHTTPClient(), []string{server.URL}, "all", 50*time.Millisecond, 5*time.Millisecond)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", report.Status)
+		assert.Less(t, time.Since(started), time.Second)
+	})
+}
+
+// Returns server that never responds, until the test is over.
+func fakeHangingServer(t *testing.T) *httptest.Server {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	return server
+}
+
+func TestRetryHealthStatus(t *testing.T) {
+	fakeFlakyServer := func(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server, &calls
+	}
+
+	t.Run("returns HEALTHY after a single failure", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 1)
+
+		report, err := retryHealthStatus(server.Client(), []string{server.URL}, "all", 3, time.Millisecond)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", report.Status)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("does not retry without retries", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 1)
+
+		report, err := retryHealthStatus(server.Client(), []string{server.URL}, "all", 0, time.Millisecond)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", report.Status)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("returns UNHEALTHY when retries are exhausted", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 1000)
+
+		report, err := retryHealthStatus(server.Client(), []string{server.URL}, "all", 2, time.Millisecond)
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Equal(t, "UNHEALTHY", report.Status)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("gives up requests to unresponsive server after timeout", func(t *testing.T) {
+		server := fakeHangingServer(t)
+
+		report, err := retryHealthStatus(newHTTPClient(withTimeout(20*time.Millisecond)), []string{server.URL}, "all", 1, time.Millisecond)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, "UNHEALTHY", report.Status)
+	})
+}
+
+func TestRepeatHealthChecks(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buf bytes.Buffer
+
+	done := make(chan error)
+
+	go func() {
+		done <- repeatHealthChecks(ctx, &buf, server.Client(), []string{server.URL}, "all", time.Millisecond, true)
+	}()
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+
+	cancel()
+
+	assert.Nil(t, <-done, "expected no error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	assert.GreaterOrEqual(t, len(lines), 3)
+	assert.Contains(t, lines[0], `"status":"HEALTHY"`)
+	assert.Contains(t, lines[1], `"status":"UNHEALTHY"`)
+	assert.Contains(t, lines[2], `"status":"HEALTHY"`)
+}
+
+func TestCheckEndpointsHealth(t *testing.T) {
+	fakeHealthServer := func(t *testing.T, statusCode int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	healthy := fakeHealthServer(t, http.StatusOK)
+	unhealthy := fakeHealthServer(t, http.StatusServiceUnavailable)
+
+	t.Run("reports single endpoint as is", func(t *testing.T) {
+		report, err := checkEndpointsHealth(healthy.Client(), []string{healthy.URL}, "all")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY", report.Status)
+		assert.Equal(t, healthy.URL, report.Endpoint)
+		assert.Empty(t, report.Endpoints)
+	})
+
+	t.Run("with all mode", func(t *testing.T) {
+		t.Run("is healthy when all endpoints are healthy", func(t *testing.T) {
+			report, err := checkEndpointsHealth(healthy.Client(), []string{healthy.URL, healthy.URL}, "all")
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, "HEALTHY", report.Status)
+			assert.Len(t, report.Endpoints, 2)
+		})
+
+		t.Run("is unhealthy when any endpoint is unhealthy", func(t *testing.T) {
+			report, err := checkEndpointsHealth(healthy.Client(), []string{healthy.URL, unhealthy.URL}, "all")
+
+			assert.ErrorContains(t, err, unhealthy.URL+": non-OK status")
+			assert.Equal(t, "UNHEALTHY", report.Status)
+
+			if assert.Len(t, report.Endpoints, 2) {
+				assert.Equal(t, "HEALTHY", report.Endpoints[0].Status)
+				assert.Equal(t, "UNHEALTHY", report.Endpoints[1].Status)
+				assert.Equal(t, unhealthy.URL, report.Endpoints[1].Endpoint)
+			}
+		})
+	})
+
+	t.Run("with any mode", func(t *testing.T) {
+		t.Run("is healthy when any endpoint is healthy", func(t *testing.T) {
+			report, err := checkEndpointsHealth(healthy.Client(), []string{unhealthy.URL, healthy.URL}, "any")
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, "HEALTHY", report.Status)
+			assert.Empty(t, report.Error)
+
+			if assert.Len(t, report.Endpoints, 2) {
+				assert.Equal(t, "UNHEALTHY", report.Endpoints[0].Status)
+				assert.Equal(t, "HEALTHY", report.Endpoints[1].Status)
+			}
+		})
+
+		t.Run("is unhealthy when all endpoints are unhealthy", func(t *testing.T) {
+			report, err := checkEndpointsHealth(healthy.Client(), []string{unhealthy.URL, unhealthy.URL}, "any")
+
+			assert.NotNil(t, err, "expected an error")
+			assert.Equal(t, "UNHEALTHY", report.Status)
+		})
+	})
+}
+
+func TestHealthMetricsStatePath(t *testing.T) {
+	oldEndpoints, oldState := healthEndpoints, healthMetricsState
+
+	t.Cleanup(func() {
+		healthEndpoints, healthMetricsState = oldEndpoints, oldState
+	})
+
+	healthMetricsState = "/tmp/metrics.json"
+
+	t.Run("returns state file as is for single endpoint", func(t *testing.T) {
+		healthEndpoints = []string{"http://forwarder:2020/api/v1/health"}
+
+		assert.Equal(t, "/tmp/metrics.json", healthMetricsStatePath(healthEndpoints[0]))
+	})
+
+	t.Run("returns distinct state files for multiple endpoints", func(t *testing.T) {
+		healthEndpoints = []string{"http://forwarder:2020/api/v1/health", "http://aggregator:2020/api/v1/health"}
+
+		forwarder := healthMetricsStatePath(healthEndpoints[0])
+		aggregator := healthMetricsStatePath(healthEndpoints[1])
+
+		assert.Regexp(t, `^/tmp/metrics\.[0-9a-f]{8}\.json$`, forwarder)
+		assert.Regexp(t, `^/tmp/metrics\.[0-9a-f]{8}\.json$`, aggregator)
+		assert.NotEqual(t, forwarder, aggregator)
+	})
+}
+
+func TestWriteHealthReport(t *testing.T) {
+	t.Run("writes bare status", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		report := newHealthReport("HEALTHY", "http://localhost:2020/api/v1/health", 12*time.Millisecond, nil)
+
+		assert.Nil(t, writeHealthReport(&buf, report, false))
+		assert.Equal(t, "HEALTHY\n", buf.String())
+	})
+
+	t.Run("writes JSON object", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		report := newHealthReport("HEALTHY", "http://localhost:2020/api/v1/health", 12*time.Millisecond, nil)
+
+		assert.Nil(t, writeHealthReport(&buf, report, true))
+		assert.JSONEq(t, `{"status":"HEALTHY","endpoint":"http://localhost:2020/api/v1/health","latency_ms":12}`, buf.String())
+	})
+
+	t.Run("writes JSON object with endpoints", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		report := newHealthReport("HEALTHY", "", 5*time.Millisecond, nil)
+		report.Endpoints = []healthReport{
+			newHealthReport("HEALTHY", "http://forwarder:2020/api/v1/health", 2*time.Millisecond, nil),
+			newHealthReport("HEALTHY", "http://aggregator:2020/api/v1/health", 3*time.Millisecond, nil),
+		}
+
+		assert.Nil(t, writeHealthReport(&buf, report, true))
+		assert.JSONEq(t, `{"status":"HEALTHY","latency_ms":5,"endpoints":[
+			{"status":"HEALTHY","endpoint":"http://forwarder:2020/api/v1/health","latency_ms":2},
+			{"status":"HEALTHY","endpoint":"http://aggregator:2020/api/v1/health","latency_ms":3}
+		]}`, buf.String())
+	})
+
+	t.Run("writes JSON object with error", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		report := newHealthReport("UNHEALTHY", "http://localhost:2020/api/v1/health", 3*time.Millisecond, errors.New("connection refused"))
+
+		assert.Nil(t, writeHealthReport(&buf, report, true))
+		assert.JSONEq(t, `{"status":"UNHEALTHY","endpoint":"http://localhost:2020/api/v1/health","latency_ms":3,"error":"connection refused"}`, buf.String())
+	})
+}
+
+func TestHealthCmdRunE(t *testing.T) {
+	runHealth := func(t *testing.T, endpoints ...string) (string, error) {
+		t.Helper()
+
+		var buf bytes.Buffer
+
+		oldEndpoints, oldOut := healthEndpoints, healthCmd.OutOrStdout()
+
+		t.Cleanup(func() {
+			healthEndpoints = oldEndpoints
+			healthCmd.SetOut(oldOut)
+		})
+
+		healthEndpoints = endpoints
+		healthCmd.SetOut(&buf)
+
+		err := healthCmdRunE(healthCmd, nil)
+
+		return buf.String(), err
+	}
+
+	fakeHealthServer := func(t *testing.T, statusCode int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	t.Run("exits with zero code when healthy", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusOK)
+
+		out, err := runHealth(t, server.URL)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "HEALTHY\n", out)
+	})
+
+	t.Run("exits with unhealthy code on non-OK status", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusInternalServerError)
+
+		out, err := runHealth(t, server.URL)
+
+		var exitErr *exitError
+
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, healthExitUnhealthy, exitErr.code)
+		assert.Equal(t, "UNHEALTHY\n", out)
+	})
+
+	t.Run("exits with unhealthy code when server is unreachable", func(t *testing.T) {
+		server := fakeHealthServer(t, http.StatusOK)
+		server.Close()
+
+		out, err := runHealth(t, server.URL)
+
+		var exitErr *exitError
+
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, healthExitUnhealthy, exitErr.code)
+		assert.Equal(t, "UNHEALTHY\n", out)
+	})
+
+	t.Run("exits with unhealthy code when any of multiple endpoints is unhealthy", func(t *testing.T) {
+		healthy := fakeHealthServer(t, http.StatusOK)
+		unhealthy := fakeHealthServer(t, http.StatusInternalServerError)
+
+		out, err := runHealth(t, healthy.URL, unhealthy.URL)
+
+		var exitErr *exitError
+
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, healthExitUnhealthy, exitErr.code)
+		assert.Equal(t, "UNHEALTHY\n", out)
+	})
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+type httpClientOption func(*http.Client)
+
+// Sets overall time limit of requests made by the client.
+func withTimeout(timeout time.Duration) httpClientOption {
+	return func(c *http.Client) {
+		c.Timeout = timeout
+	}
+}
+
+// Sets transport used by the client to make requests.
+func withTransport(transport http.RoundTripper) httpClientOption {
+	return func(c *http.Client) {
+		c.Transport = transport
+	}
+}
+
+// Sets User-Agent header of requests, that don't have one set explicitly.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Logs DNS, connect and TLS timings of requests at debug level.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var dnsStart, connectStart, tlsStart time.Time
+
+	start := time.Now()
+	logger := slog.With("method", req.Method, "url", req.URL.Redacted())
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			logger.Debug("HTTP trace: DNS lookup done", "duration", time.Since(dnsStart), "addrs", info.Addrs, "error", info.Err)
+		},
+		ConnectStart: func(network, addr string) { connectStart = time.Now() },
+		ConnectDone: func(network, addr string, err error) {
+			logger.Debug("HTTP trace: connection established", "duration", time.Since(connectStart), "network", network, "addr", addr, "error", err)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			logger.Debug("HTTP trace: TLS handshake done", "duration", time.Since(tlsStart), "error", err)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			logger.Debug("HTTP trace: got connection", "reused", info.Reused, "elapsed", time.Since(start))
+		},
+		GotFirstResponseByte: func() {
+			logger.Debug("HTTP trace: got first response byte", "elapsed", time.Since(start))
+		},
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := t.base.RoundTrip(req)
+
+	if err != nil {
+		logger.Debug("HTTP trace: request failed", "elapsed", time.Since(start), "error", err)
+	} else {
+		logger.Debug("HTTP trace: got response", "elapsed", time.Since(start), "status", res.StatusCode)
+	}
+
+	return res, err
+}
+
+// Returns User-Agent identifying this tool, e.g. in logs of proxies.
+func userAgent() string {
+	return "fluent-bit-for-ecs/" + version
+}
+
+// Returns new HTTP client. Unlike `http.DefaultClient` it does not share its
+// transport with anything else, so tweaking one client never affects others.
+// Requests made by the client identify this tool with User-Agent header, and
+// with `--trace-http` their timings are logged at debug level.
+func newHTTPClient(opts ...httpClientOption) *http.Client {
+	client := &http.Client{
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if traceHTTP {
+		client.Transport = &tracingTransport{base: client.Transport}
+	}
+
+	client.Transport = &userAgentTransport{base: client.Transport, userAgent: userAgent()}
+
+	return client
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	t.Run("does not share default transport", func(t *testing.T) {
+		client := newHTTPClient()
+
+		assert.NotNil(t, client.Transport)
+		assert.NotSame(t, http.DefaultTransport, client.Transport)
+		assert.Zero(t, client.Timeout)
+	})
+
+	t.Run("applies options", func(t *testing.T) {
+		transport := &http.Transport{}
+		client := newHTTPClient(withTimeout(3*time.Second), withTransport(transport))
+
+		if assert.IsType(t, &userAgentTransport{}, client.Transport) {
+			assert.Same(t, transport, client.Transport.(*userAgentTransport).base)
+		}
+
+		assert.Equal(t, 3*time.Second, client.Timeout)
+	})
+
+	t.Run("identifies itself with User-Agent", func(t *testing.T) {
+		var userAgents []string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userAgents = append(userAgents, r.UserAgent())
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newHTTPClient()
+
+		_, err := client.Get(server.URL)
+		assert.Nil(t, err, "expected no error")
+
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("User-Agent", "custom/1.0")
+
+		_, err = client.Do(req)
+		assert.Nil(t, err, "expected no error")
+
+		assert.Equal(t, []string{"fluent-bit-for-ecs/" + version, "custom/1.0"}, userAgents)
+	})
+
+	t.Run("traces requests with --trace-http", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		oldLogger, oldTrace := slog.Default(), traceHTTP
+
+		t.Cleanup(func() {
+			slog.SetDefault(oldLogger)
+			traceHTTP = oldTrace
+		})
+
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		t.Cleanup(server.Close)
+
+		traceHTTP = false
+		_, err := newHTTPClient().Get(server.URL)
+
+		assert.Nil(t, err, "expected no error")
+		assert.NotContains(t, buf.String(), "HTTP trace")
+
+		traceHTTP = true
+		client := newHTTPClient()
+
+		if assert.IsType(t, &userAgentTransport{}, client.Transport) {
+			assert.IsType(t, &tracingTransport{}, client.Transport.(*userAgentTransport).base)
+		}
+
+		_, err = client.Get(server.URL)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Contains(t, buf.String(), `msg="HTTP trace: connection established"`)
+		assert.Contains(t, buf.String(), `msg="HTTP trace: got response"`)
+		assert.Contains(t, buf.String(), "status=200")
+	})
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metadataRaw     bool
+	metadataExplain bool
+)
+
+// Value of a managed variable along with its source.
+type explainedValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Returns managed variables resolved against the current environment, with
+// the source of each value.
+func explainEnviron(m *ecsmeta.Metadata) []explainedValue {
+	explained := make([]explainedValue, 0, len(ecsmeta.EnvKeys))
+
+	for _, v := range m.Environ() {
+		key, value, _ := strings.Cut(v, "=")
+		value, source := resolveManagedValue(key, value)
+
+		explained = append(explained, explainedValue{Key: envPrefix + key, Value: value, Source: source})
+	}
+
+	return explained
+}
+
+// metadataCmd represents the metadata command
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Prints resolved ECS task metadata as JSON",
+	Args:  cobra.NoArgs,
+	RunE:  metadataCmdRunE,
+}
+
+func metadataCmdRunE(cmd *cobra.Command, args []string) error {
+	if err := validatePrefer(envPrefer); err != nil {
+		return err
+	}
+
+	if path := ecsMetadataFile(); metadataRaw && path != "" {
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			slog.Error("Can't read ECS task metadata", "error", err)
+			return err
+		}
+
+		_, err = cmd.OutOrStdout().Write(body)
+
+		return err
+	}
+
+	if metadataRaw {
+		metadataClient, err := newMetadataClient(newHTTPClient())
+
+		if err != nil {
+			return err
+		}
+
+		body, err := metadataClient.FetchRaw(cmd.Context(), metadataClient.TaskPath)
+
+		if errors.Is(err, ecsmeta.ErrEndpointNotSet) {
+			return errors.New("neither --metadata-uri nor ECS_CONTAINER_METADATA_URI_V4 environment variable is set")
+		}
+
+		if err != nil {
+			slog.Error("Can't retrieve ECS task metadata", "error", err)
+			return err
+		}
+
+		_, err = cmd.OutOrStdout().Write(body)
+
+		return err
+	}
+
+	metadata, err := getEcsTaskMetadata(cmd.Context(), newHTTPClient())
+
+	if err != nil {
+		slog.Error("Can't retrieve ECS task metadata", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	encoder.SetIndent("", "  ")
+
+	if metadataExplain {
+		return encoder.Encode(explainEnviron(metadata))
+	}
+
+	return encoder.Encode(metadata)
+}
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+
+	addMetadataEndpointFlags(metadataCmd)
+	addMetadataCacheFlags(metadataCmd)
+	addTemplateFlags(metadataCmd)
+	addEnrichFlags(metadataCmd)
+	addClusterArnStyleFlag(metadataCmd)
+	addPreferFlag(metadataCmd)
+	metadataCmd.Flags().BoolVar(&metadataRaw, "raw", false, "Print untouched task metadata document as returned by the endpoint")
+	metadataCmd.Flags().BoolVar(&metadataExplain, "explain", false, "Print each variable's value resolved against the environment, and its source: metadata, derived, api, env or default")
+
+	metadataCmd.MarkFlagsMutuallyExclusive("raw", "explain")
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metadataReadyTimeout  = 60 * time.Second
+	metadataReadyInterval = time.Second
+)
+
+// metadataReadyCmd represents the metadata-ready command
+var metadataReadyCmd = &cobra.Command{
+	Use:   "metadata-ready",
+	Short: "Waits until ECS task metadata can be retrieved",
+	Long: `Polls ECS task metadata endpoint until it returns a document with the task
+ARN, which might take a moment right after the task start. Exits with non-zero
+code if metadata is not available before the timeout elapses.`,
+	Args: cobra.NoArgs,
+	RunE: metadataReadyCmdRunE,
+}
+
+// Polls metadata endpoint every `interval` until it returns metadata with the
+// task ARN or the `timeout` elapses. Returns the last seen error.
+func waitForEcsTaskMetadata(ctx context.Context, client *http.Client, timeout, interval time.Duration) (*ecsmeta.Metadata, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		metadata, err := getEcsTaskMetadata(ctx, client)
+
+		if err == nil && metadata.EcsTaskARN == "" {
+			err = errors.New("no task ARN in ECS task metadata")
+		}
+
+		if err == nil {
+			return metadata, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("not ready after %s: %w", timeout, err)
+		}
+
+		slog.Debug("ECS task metadata is not ready yet", "error", err, "retry_in", interval)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func metadataReadyCmdRunE(cmd *cobra.Command, args []string) error {
+	if ecsMetadataEndpoint() == "" && ecsMetadataFile() == "" {
+		return errors.New("neither --metadata-uri, --metadata-file nor ECS_CONTAINER_METADATA_URI_V4 environment variable is set")
+	}
+
+	metadata, err := waitForEcsTaskMetadata(cmd.Context(), newHTTPClient(), metadataReadyTimeout, metadataReadyInterval)
+
+	if err != nil {
+		slog.Error("ECS task metadata is not ready", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	slog.Info("ECS task metadata is ready", "task_arn", metadata.EcsTaskARN)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(metadataReadyCmd)
+
+	addMetadataEndpointFlags(metadataReadyCmd)
+	addMetadataCacheFlags(metadataReadyCmd)
+	metadataReadyCmd.Flags().DurationVar(&metadataReadyTimeout, "timeout", metadataReadyTimeout, "Maximum time to wait for ECS task metadata")
+	metadataReadyCmd.Flags().DurationVar(&metadataReadyInterval, "interval", metadataReadyInterval, "Interval between metadata polls")
+}
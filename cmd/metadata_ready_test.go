@@ -0,0 +1,130 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForEcsTaskMetadata(t *testing.T) {
+	fakeBootingServer := func(t *testing.T, failures int32, body string) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/task" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if calls.Add(1) <= failures {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+
+		t.Cleanup(server.Close)
+
+		oldURI := metadataURI
+		t.Cleanup(func() { metadataURI = oldURI })
+
+		metadataURI = server.URL
+
+		return server, &calls
+	}
+
+	t.Run("returns as soon as metadata becomes available", func(t *testing.T) {
+		_, calls := fakeBootingServer(t, 2, `{"TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef"}`)
+
+		metadata, err := waitForEcsTaskMetadata(context.Background(), newHTTPClient(), time.Second, time.Millisecond)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "deadbeef", metadata.EcsTaskID)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("returns error when metadata has no task ARN", func(t *testing.T) {
+		fakeBootingServer(t, 0, `{}`)
+
+		metadata, err := waitForEcsTaskMetadata(context.Background(), newHTTPClient(), 20*time.Millisecond, 5*time.Millisecond)
+
+		assert.ErrorContains(t, err, "no task ARN")
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("returns error when timeout elapses", func(t *testing.T) {
+		fakeBootingServer(t, 1000, `{}`)
+
+		metadata, err := waitForEcsTaskMetadata(context.Background(), newHTTPClient(), 20*time.Millisecond, 5*time.Millisecond)
+
+		assert.ErrorContains(t, err, "not ready after 20ms")
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("returns error when context is cancelled", func(t *testing.T) {
+		fakeBootingServer(t, 1000, `{}`)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		metadata, err := waitForEcsTaskMetadata(ctx, newHTTPClient(), time.Second, time.Millisecond)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, metadata)
+	})
+}
+
+func TestMetadataReadyCmdRunE(t *testing.T) {
+	oldMetadataURI, oldMetadataFile := metadataURI, metadataFile
+	t.Cleanup(func() { metadataURI, metadataFile = oldMetadataURI, oldMetadataFile })
+
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+	t.Run("fails without metadata source", func(t *testing.T) {
+		metadataURI, metadataFile = "", ""
+
+		assert.ErrorContains(t, metadataReadyCmdRunE(metadataReadyCmd, nil), "neither --metadata-uri, --metadata-file nor")
+	})
+
+	t.Run("reads metadata from --metadata-file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "task.json")
+
+		if err := os.WriteFile(path, []byte(`{ "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef" }`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		metadataURI, metadataFile = "", path
+
+		assert.Nil(t, metadataReadyCmdRunE(metadataReadyCmd, nil))
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainEnviron(t *testing.T) {
+	for _, key := range ecsmeta.EnvKeys {
+		t.Setenv(key, "")
+	}
+
+	metadata := ecsmeta.Metadata{EcsClusterName: "cluster-name", AwsRegion: "aws-region-1"}
+
+	explainedValueOf := func(explained []explainedValue, key string) explainedValue {
+		for _, v := range explained {
+			if v.Key == key {
+				return v
+			}
+		}
+
+		t.Fatalf("%s is not explained", key)
+
+		return explainedValue{}
+	}
+
+	t.Run("explains each of managed variables", func(t *testing.T) {
+		assert.Len(t, explainEnviron(&metadata), len(ecsmeta.EnvKeys))
+		assert.Subset(t, ecsmeta.EnvKeys, derivedEnvKeys)
+		assert.Subset(t, ecsmeta.EnvKeys, apiEnvKeys)
+	})
+
+	t.Run("when --prefer=metadata", func(t *testing.T) {
+		t.Setenv("ECS_CLUSTER_NAME", "existing-cluster")
+		t.Setenv("ECS_SERVICE_NAME", "existing-service")
+
+		explained := explainEnviron(&metadata)
+
+		assert.Equal(t, explainedValue{"ECS_CLUSTER_NAME", "cluster-name", "metadata"}, explainedValueOf(explained, "ECS_CLUSTER_NAME"))
+		assert.Equal(t, explainedValue{"AWS_REGION", "aws-region-1", "derived"}, explainedValueOf(explained, "AWS_REGION"))
+		assert.Equal(t, explainedValue{"ECS_SERVICE_NAME", "existing-service", "env"}, explainedValueOf(explained, "ECS_SERVICE_NAME"))
+		assert.Equal(t, explainedValue{"ECS_TASK_ARN", "", "default"}, explainedValueOf(explained, "ECS_TASK_ARN"))
+	})
+
+	t.Run("when --prefer=env", func(t *testing.T) {
+		oldPrefer := envPrefer
+		t.Cleanup(func() { envPrefer = oldPrefer })
+
+		envPrefer = "env"
+
+		t.Setenv("ECS_CLUSTER_NAME", "existing-cluster")
+
+		explained := explainEnviron(&metadata)
+
+		assert.Equal(t, explainedValue{"ECS_CLUSTER_NAME", "existing-cluster", "env"}, explainedValueOf(explained, "ECS_CLUSTER_NAME"))
+		assert.Equal(t, explainedValue{"AWS_REGION", "aws-region-1", "derived"}, explainedValueOf(explained, "AWS_REGION"))
+	})
+}
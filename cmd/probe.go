@@ -0,0 +1,77 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	probeAddress = "127.0.0.1:24224"
+	probeTimeout = 2 * time.Second
+)
+
+// probeCmd represents the probe command
+var probeCmd = &cobra.Command{
+	Use:   "probe",
+	Short: "Checks that Fluent-Bit forward input accepts connections",
+	Long: `Opens TCP connection to Fluent-Bit forward input, and closes it right away.
+Exits with non-zero code if connection can't be established.
+
+Unlike health command, this ensures that the log ingestion socket is listening,
+and not just the HTTP server.`,
+	Args: cobra.NoArgs,
+	RunE: probeCmdRunE,
+}
+
+// Dials TCP `address` and closes the connection once it's established.
+func probeTCP(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func probeCmdRunE(cmd *cobra.Command, args []string) error {
+	if err := probeTCP(probeAddress, probeTimeout); err != nil {
+		slog.Error("Fluent-Bit forward input does not accept connections", "address", probeAddress, "error", err)
+		return err
+	}
+
+	slog.Debug("Fluent-Bit forward input accepts connections", "address", probeAddress)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(probeCmd)
+
+	probeCmd.Flags().StringVar(&probeAddress, "address", probeAddress, "Fluent-Bit forward input address (host:port)")
+	probeCmd.Flags().DurationVar(&probeTimeout, "timeout", probeTimeout, "Maximum time to wait for connection")
+}
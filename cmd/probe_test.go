@@ -0,0 +1,56 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeTCP(t *testing.T) {
+	t.Run("succeeds when address accepts connections", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+		assert.Nil(t, err, "expected no error")
+		t.Cleanup(func() { listener.Close() })
+
+		assert.Nil(t, probeTCP(listener.Addr().String(), time.Second))
+	})
+
+	t.Run("fails when nothing listens on address", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+		assert.Nil(t, err, "expected no error")
+
+		address := listener.Addr().String()
+		listener.Close()
+
+		assert.NotNil(t, probeTCP(address, time.Second), "expected an error")
+	})
+
+	t.Run("fails when address is malformed", func(t *testing.T) {
+		assert.NotNil(t, probeTCP("wazzup", time.Second), "expected an error")
+	})
+}
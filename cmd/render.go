@@ -0,0 +1,131 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderFilterName = "record_modifier"
+	renderMatch      = "*"
+	renderOutput     string
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Renders Fluent-Bit filter adding ECS task metadata to records",
+	Long: `Renders Fluent-Bit [FILTER] section, that adds ECS task metadata as record
+fields, e.g. AWS_REGION becomes aws_region. Fields with empty values are
+omitted, and values with spaces or special characters are quoted.
+
+The file given with --output is readable by everyone, so that Fluent-Bit can
+read it even if running as another user.`,
+	Args: cobra.NoArgs,
+	RunE: renderCmdRunE,
+}
+
+// Writes Fluent-Bit [FILTER] section using `filterName` plugin (either
+// record_modifier or modify), adding non-empty environment variables as record
+// fields named after lowercased keys.
+func writeFilter(w io.Writer, filterName, match string, environ []string) error {
+	var directive string
+
+	switch filterName {
+	case "record_modifier":
+		directive = "Record"
+	case "modify":
+		directive = "Add"
+	default:
+		return fmt.Errorf("unsupported filter %q (expected record_modifier or modify)", filterName)
+	}
+
+	lines := []string{"[FILTER]", "    Name   " + filterName, "    Match  " + match}
+
+	for _, v := range environ {
+		key, value, _ := strings.Cut(v, "=")
+
+		if value == "" {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("    %-6s %s %s", directive, strings.ToLower(key), quoteFilterValue(value)))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return err
+}
+
+// Returns `value` as is if it's safe to use in Fluent-Bit classic config,
+// or double-quoted otherwise (e.g. when it contains spaces or `#`), with
+// backslashes and double quotes escaped. Line breaks can't be represented,
+// so they are replaced with spaces.
+func quoteFilterValue(value string) string {
+	value = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+
+	if !strings.ContainsAny(value, " \t#\"\\'") {
+		return value
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func renderCmdRunE(cmd *cobra.Command, args []string) error {
+	metadata, err := getEcsTaskMetadata(cmd.Context(), newHTTPClient())
+
+	if err != nil {
+		slog.Error("Can't retrieve ECS task metadata", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	environ := managedEnviron(metadata)
+
+	if renderOutput == "" || renderOutput == "-" {
+		return writeFilter(cmd.OutOrStdout(), renderFilterName, renderMatch, environ)
+	}
+
+	return writeFileAtomicMode(renderOutput, 0o644, func(w io.Writer) error {
+		return writeFilter(w, renderFilterName, renderMatch, environ)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	addMetadataEndpointFlags(renderCmd)
+	addMetadataCacheFlags(renderCmd)
+	addTemplateFlags(renderCmd)
+	addEnrichFlags(renderCmd)
+	addClusterArnStyleFlag(renderCmd)
+	renderCmd.Flags().StringVar(&renderFilterName, "filter-name", renderFilterName, "Filter plugin to render: record_modifier or modify")
+	renderCmd.Flags().StringVar(&renderMatch, "match", renderMatch, "Tag pattern the filter applies to")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "Write filter to the given file instead of stdout")
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFilter(t *testing.T) {
+	environ := []string{"AWS_REGION=aws-region-1", "ECS_SERVICE_NAME=", "ECS_TASK_ID=deadbeef"}
+
+	t.Run("record_modifier", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeFilter(&buf, "record_modifier", "*", environ), "expected no error")
+		assert.Equal(t, `[FILTER]
+    Name   record_modifier
+    Match  *
+    Record aws_region aws-region-1
+    Record ecs_task_id deadbeef
+`, buf.String())
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeFilter(&buf, "modify", "app.*", environ), "expected no error")
+		assert.Equal(t, `[FILTER]
+    Name   modify
+    Match  app.*
+    Add    aws_region aws-region-1
+    Add    ecs_task_id deadbeef
+`, buf.String())
+	})
+
+	t.Run("quotes values with spaces and special characters", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		environ := []string{"ECS_CLUSTER_NAME=cluster name", "ECS_SERVICE_NAME=service#1", `ECS_IMAGE=say "hi" \o/`}
+
+		assert.Nil(t, writeFilter(&buf, "record_modifier", "*", environ), "expected no error")
+		assert.Equal(t, `[FILTER]
+    Name   record_modifier
+    Match  *
+    Record ecs_cluster_name "cluster name"
+    Record ecs_service_name "service#1"
+    Record ecs_image "say \"hi\" \\o/"
+`, buf.String())
+	})
+
+	t.Run("unsupported filter", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NotNil(t, writeFilter(&buf, "lua", "*", environ), "expected an error")
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestQuoteFilterValue(t *testing.T) {
+	assert.Equal(t, "deadbeef", quoteFilterValue("deadbeef"))
+	assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name", quoteFilterValue("arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name"))
+	assert.Equal(t, `"service name"`, quoteFilterValue("service name"))
+	assert.Equal(t, `"service#1"`, quoteFilterValue("service#1"))
+	assert.Equal(t, `"multi line"`, quoteFilterValue("multi\nline"))
+}
+
+func TestRenderCmdRunE(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "task.json")
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{ "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef", "ServiceName": "service #1" }`), 0o600))
+
+	oldMetadataURI, oldMetadataFile, oldOutput := metadataURI, metadataFile, renderOutput
+	t.Cleanup(func() { metadataURI, metadataFile, renderOutput = oldMetadataURI, oldMetadataFile, oldOutput })
+
+	for _, key := range []string{"ECS_CONTAINER_METADATA_URI_V4", "ECS_SERVICE_NAME", "ECS_LOG_GROUP", "ECS_DEPLOYMENT_KEY"} {
+		t.Setenv(key, "")
+	}
+
+	metadataURI, metadataFile, renderOutput = "", path, filepath.Join(dir, "filter.conf")
+
+	assert.Nil(t, renderCmdRunE(renderCmd, nil))
+
+	info, err := os.Stat(renderOutput)
+
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm(), "expected filter to be readable by everyone")
+
+	filter, err := os.ReadFile(renderOutput)
+
+	assert.Nil(t, err)
+	assert.Contains(t, string(filter), `Record ecs_service_name "service #1"`)
+	assert.Contains(t, string(filter), `Record ecs_log_group "/ecs/cluster-name/service #1"`)
+}
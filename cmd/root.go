@@ -22,20 +22,124 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logLevel  = defaultLogLevel()
+	logFormat = firstNonEmpty(os.Getenv("FLUENT_BIT_FOR_ECS_LOG_FORMAT"), "text")
+	logQuiet  bool
+	traceHTTP bool
+)
+
 var rootCmd = &cobra.Command{
-	Use:           "fluent-bit-for-ecs",
-	SilenceErrors: true,
-	SilenceUsage:  true,
+	Use:               "fluent-bit-for-ecs",
+	SilenceErrors:     true,
+	SilenceUsage:      true,
+	PersistentPreRunE: rootCmdPersistentPreRunE,
+}
+
+// Returns log level from FLUENT_BIT_FOR_ECS_LOG_LEVEL environment variable,
+// falling back to `debug` when FLUENT_BIT_FOR_ECS_DEBUG=1, and `info` otherwise.
+func defaultLogLevel() string {
+	if level := os.Getenv("FLUENT_BIT_FOR_ECS_LOG_LEVEL"); level != "" {
+		return level
+	}
+
+	if os.Getenv("FLUENT_BIT_FOR_ECS_DEBUG") == "1" {
+		return "debug"
+	}
+
+	return "info"
+}
+
+func newLogHandler(w io.Writer, level, format string) (slog.Handler, error) {
+	var l slog.Level
+
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "text":
+		if shouldColorize(w) {
+			w = &levelColorWriter{w: w}
+		}
+
+		return slog.NewTextHandler(w, opts), nil
+
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+}
+
+// Returns log level to use. Quiet mode wins over explicitly given level.
+func effectiveLogLevel(level string, quiet bool) string {
+	if quiet {
+		return "error"
+	}
+
+	return level
+}
+
+// Returns `logger` that adds ECS task ID and cluster name from `metadata` to
+// every record as `ecs.task_id` and `ecs.cluster` attributes, when logging in
+// JSON format, so that own logs can be correlated with ones of the task.
+func withMetadataLogAttrs(logger *slog.Logger, format string, metadata *ecsmeta.Metadata) *slog.Logger {
+	if format != "json" || metadata == nil || metadata.EcsTaskID == "" {
+		return logger
+	}
+
+	return logger.With(slog.Group("ecs", "task_id", metadata.EcsTaskID, "cluster", metadata.EcsClusterName))
+}
+
+func rootCmdPersistentPreRunE(cmd *cobra.Command, args []string) error {
+	handler, err := newLogHandler(os.Stderr, effectiveLogLevel(logLevel, logQuiet), logFormat)
+
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return nil
+}
+
+// exitError makes Execute terminate the process with the given exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit code %d: %v", e.code, e.err)
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+
+		if errors.As(err, &exitErr) {
+			slog.Debug("Exiting", "code", exitErr.code, "error", exitErr.err)
+			os.Exit(exitErr.code)
+		}
+
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
@@ -43,4 +147,9 @@ func Execute() {
 
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", logLevel, "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().BoolVarP(&logQuiet, "quiet", "q", false, "Log errors only (overrides --log-level)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "Log format: text or json")
+	rootCmd.PersistentFlags().BoolVar(&traceHTTP, "trace-http", false, "Log DNS, connect and TLS timings of HTTP requests at debug level")
 }
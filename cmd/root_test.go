@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	t.Run("returns text handler", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		handler, err := newLogHandler(&buf, "warn", "text")
+
+		assert.Nil(t, err, "expected no error")
+
+		logger := slog.New(handler)
+		logger.Info("hidden")
+		logger.Warn("visible")
+
+		assert.NotContains(t, buf.String(), "hidden")
+		assert.Contains(t, buf.String(), "level=WARN msg=visible")
+	})
+
+	t.Run("returns json handler", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		handler, err := newLogHandler(&buf, "DEBUG", "json")
+
+		assert.Nil(t, err, "expected no error")
+
+		slog.New(handler).Debug("visible")
+
+		assert.Contains(t, buf.String(), `"level":"DEBUG","msg":"visible"`)
+	})
+
+	t.Run("returns error on invalid level", func(t *testing.T) {
+		handler, err := newLogHandler(&bytes.Buffer{}, "verbose", "text")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, handler)
+	})
+
+	t.Run("returns error on invalid format", func(t *testing.T) {
+		handler, err := newLogHandler(&bytes.Buffer{}, "info", "yaml")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, handler)
+	})
+}
+
+func TestWithMetadataLogAttrs(t *testing.T) {
+	metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsTaskID: "deadbeef"}
+
+	t.Run("adds ECS attributes to JSON records", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		withMetadataLogAttrs(slog.New(slog.NewJSONHandler(&buf, nil)), "json", metadata).Info("hello")
+
+		assert.Contains(t, buf.String(), `"level":"INFO","msg":"hello","ecs":{"task_id":"deadbeef","cluster":"cluster-name"}}`)
+	})
+
+	t.Run("leaves text records intact", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+		assert.Same(t, logger, withMetadataLogAttrs(logger, "text", metadata))
+	})
+
+	t.Run("leaves logger intact without task ID", func(t *testing.T) {
+		logger := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+
+		assert.Same(t, logger, withMetadataLogAttrs(logger, "json", &ecsmeta.Metadata{EcsClusterName: "cluster-name"}))
+		assert.Same(t, logger, withMetadataLogAttrs(logger, "json", nil))
+	})
+}
+
+func TestEffectiveLogLevel(t *testing.T) {
+	t.Run("returns given level", func(t *testing.T) {
+		assert.Equal(t, "debug", effectiveLogLevel("debug", false))
+	})
+
+	t.Run("returns error level when quiet", func(t *testing.T) {
+		assert.Equal(t, "error", effectiveLogLevel("debug", true))
+		assert.Equal(t, "error", effectiveLogLevel("info", true))
+	})
+}
+
+func TestDefaultLogLevel(t *testing.T) {
+	t.Run("defaults to info", func(t *testing.T) {
+		t.Setenv("FLUENT_BIT_FOR_ECS_LOG_LEVEL", "")
+		t.Setenv("FLUENT_BIT_FOR_ECS_DEBUG", "")
+
+		assert.Equal(t, "info", defaultLogLevel())
+	})
+
+	t.Run("respects FLUENT_BIT_FOR_ECS_DEBUG", func(t *testing.T) {
+		t.Setenv("FLUENT_BIT_FOR_ECS_LOG_LEVEL", "")
+		t.Setenv("FLUENT_BIT_FOR_ECS_DEBUG", "1")
+
+		assert.Equal(t, "debug", defaultLogLevel())
+	})
+
+	t.Run("prefers FLUENT_BIT_FOR_ECS_LOG_LEVEL", func(t *testing.T) {
+		t.Setenv("FLUENT_BIT_FOR_ECS_LOG_LEVEL", "error")
+		t.Setenv("FLUENT_BIT_FOR_ECS_DEBUG", "1")
+
+		assert.Equal(t, "error", defaultLogLevel())
+	})
+}
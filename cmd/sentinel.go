@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+)
+
+var (
+	execOncePerBoot  bool
+	execBootSentinel = filepath.Join(os.TempDir(), "fluent-bit-for-ecs-boot.json")
+	bootIDPath       = "/proc/sys/kernel/random/boot_id"
+)
+
+// Task metadata retrieved during the boot with the given ID.
+type bootSentinel struct {
+	BootID   string           `json:"boot_id"`
+	Metadata ecsmeta.Metadata `json:"metadata"`
+}
+
+// Returns ID of the current boot, which changes on every boot of the kernel.
+func readBootID() (string, error) {
+	data, err := os.ReadFile(bootIDPath)
+
+	if err != nil {
+		return "", err
+	}
+
+	bootID := strings.TrimSpace(string(data))
+
+	if bootID == "" {
+		return "", errors.New("boot ID is empty")
+	}
+
+	return bootID, nil
+}
+
+// Returns task metadata stored in the sentinel file during the boot with the
+// given ID, unless the file is missing or was written during another boot.
+func readBootSentinel(path, bootID string) (*ecsmeta.Metadata, bool) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, false
+	}
+
+	var sentinel bootSentinel
+
+	if err := json.Unmarshal(data, &sentinel); err != nil || sentinel.BootID != bootID {
+		return nil, false
+	}
+
+	return &sentinel.Metadata, true
+}
+
+// Stores task metadata in the sentinel file, along with the current boot ID.
+func writeBootSentinel(path, bootID string, metadata *ecsmeta.Metadata) error {
+	sentinel := bootSentinel{BootID: bootID, Metadata: *metadata}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(sentinel)
+	})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBootID(t *testing.T) {
+	oldPath := bootIDPath
+
+	t.Cleanup(func() { bootIDPath = oldPath })
+
+	t.Run("returns trimmed boot ID", func(t *testing.T) {
+		bootIDPath = filepath.Join(t.TempDir(), "boot_id")
+
+		assert.Nil(t, os.WriteFile(bootIDPath, []byte("0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0\n"), 0o600))
+
+		bootID, err := readBootID()
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0", bootID)
+	})
+
+	t.Run("fails when boot ID is missing or empty", func(t *testing.T) {
+		bootIDPath = filepath.Join(t.TempDir(), "boot_id")
+
+		_, err := readBootID()
+
+		assert.NotNil(t, err, "expected an error")
+
+		assert.Nil(t, os.WriteFile(bootIDPath, []byte("\n"), 0o600))
+
+		_, err = readBootID()
+
+		assert.NotNil(t, err, "expected an error")
+	})
+}
+
+func TestBootSentinel(t *testing.T) {
+	metadata := &ecsmeta.Metadata{EcsClusterName: "cluster-name", EcsTaskID: "deadbeef"}
+
+	t.Run("reads back metadata written during the same boot", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "boot.json")
+
+		assert.Nil(t, writeBootSentinel(path, "boot-1", metadata))
+
+		stored, ok := readBootSentinel(path, "boot-1")
+
+		assert.True(t, ok, "expected sentinel hit")
+		assert.Equal(t, metadata, stored)
+	})
+
+	t.Run("misses when sentinel was written during another boot", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "boot.json")
+
+		assert.Nil(t, writeBootSentinel(path, "boot-1", metadata))
+
+		_, ok := readBootSentinel(path, "boot-2")
+
+		assert.False(t, ok, "expected sentinel miss")
+	})
+
+	t.Run("misses when sentinel is missing or malformed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "boot.json")
+
+		_, ok := readBootSentinel(path, "boot-1")
+
+		assert.False(t, ok, "expected sentinel miss")
+
+		assert.Nil(t, os.WriteFile(path, []byte("wazzup"), 0o600))
+
+		_, ok = readBootSentinel(path, "boot-1")
+
+		assert.False(t, ok, "expected sentinel miss")
+	})
+}
+
+func TestResolveExecMetadata_OncePerBoot(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/task" {
+			calls.Add(1)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Cluster": "cluster-name", "TaskARN": "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef"}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	oldOnce, oldSentinel, oldBootID, oldURI := execOncePerBoot, execBootSentinel, bootIDPath, metadataURI
+
+	t.Cleanup(func() {
+		execOncePerBoot, execBootSentinel, bootIDPath, metadataURI = oldOnce, oldSentinel, oldBootID, oldURI
+	})
+
+	dir := t.TempDir()
+	execOncePerBoot, execBootSentinel, bootIDPath, metadataURI = true, filepath.Join(dir, "boot.json"), filepath.Join(dir, "boot_id"), server.URL
+
+	assert.Nil(t, os.WriteFile(bootIDPath, []byte("boot-1\n"), 0o600))
+
+	first, err := resolveExecMetadata(context.Background())
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, "deadbeef", first.EcsTaskID)
+
+	second, err := resolveExecMetadata(context.Background())
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+
+	assert.Nil(t, os.WriteFile(bootIDPath, []byte("boot-2\n"), 0o600))
+
+	_, err = resolveExecMetadata(context.Background())
+
+	assert.Nil(t, err, "expected no error")
+	assert.Equal(t, int32(2), calls.Load())
+
+	t.Run("stores metadata as retrieved and applies flags on reuse", func(t *testing.T) {
+		oldStyle, oldTemplate := clusterArnStyle, logGroupTemplate
+		t.Cleanup(func() { clusterArnStyle, logGroupTemplate = oldStyle, oldTemplate })
+
+		clusterArnStyle, logGroupTemplate = "full", "/custom/{task_id}"
+
+		third, err := resolveExecMetadata(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name", third.EcsClusterName)
+		assert.Equal(t, "/custom/deadbeef", third.EcsLogGroup)
+
+		stored, ok := readBootSentinel(execBootSentinel, "boot-2")
+
+		assert.True(t, ok)
+		assert.Equal(t, "cluster-name", stored.EcsClusterName)
+		assert.Empty(t, stored.EcsLogGroup)
+	})
+}
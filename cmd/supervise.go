@@ -0,0 +1,198 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Signals forwarded to the supervised child process by default.
+var forwardedSignals = []string{"TERM", "INT", "QUIT"}
+
+// Returns signal by its name, with or without SIG prefix, e.g. TERM or SIGTERM.
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(name)
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+
+	return 0, fmt.Errorf("unknown signal %q", name)
+}
+
+// Returns signals with given `names`. SIGTERM is always included, as it's the
+// one that stops the child.
+func parseSignals(names []string) ([]os.Signal, error) {
+	signals := []os.Signal{unix.SIGTERM}
+
+	for _, name := range names {
+		sig, err := parseSignal(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if sig == unix.SIGKILL || sig == unix.SIGSTOP {
+			return nil, fmt.Errorf("signal %s can't be forwarded", unix.SignalName(sig))
+		}
+
+		if !slices.Contains(signals, os.Signal(sig)) {
+			signals = append(signals, sig)
+		}
+	}
+
+	return signals, nil
+}
+
+// Returns child process command sharing standard streams with this process.
+// Unlike exec.Command, it runs `argv0` as given, without looking it up in PATH
+// again: it's either resolved already, or given with `--skip-lookpath`.
+func newChildCommand(argv0 string, argv, environ []string) *exec.Cmd {
+	return &exec.Cmd{
+		Path:   argv0,
+		Args:   argv,
+		Env:    environ,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// Describes how the child process is stopped upon SIGTERM.
+//
+// ECS sends SIGTERM to the container on stop, and SIGKILL once the container's
+// `stopTimeout` (30 seconds by default) elapses. Thus grace period plus kill
+// timeout should be less than `stopTimeout`, otherwise ECS kills both this
+// process and the child before the grace period ends.
+type stopPolicy struct {
+	// Signal sent to the child instead of SIGTERM, e.g. SIGINT for apps that
+	// only shut down gracefully upon it. SIGTERM itself when nil.
+	signal os.Signal
+
+	// Delay before forwarding SIGTERM to the child, so that it keeps working
+	// (e.g. flushing logs of other containers) while the task is stopping.
+	// SIGTERM is forwarded immediately when zero, or when received again.
+	gracePeriod time.Duration
+
+	// Time the child has to exit after SIGTERM is forwarded, before being
+	// killed with SIGKILL. The child is never killed when zero.
+	killTimeout time.Duration
+}
+
+// Starts child process and waits for it to exit, forwarding every signal
+// received from `signals` to it. SIGTERM is forwarded according to `stop`,
+// possibly translated into another signal.
+func superviseChild(child *exec.Cmd, signals <-chan os.Signal, stop stopPolicy) error {
+	if err := child.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- child.Wait()
+	}()
+
+	var graceTimer, killTimer <-chan time.Time
+
+	forward := func(received os.Signal) {
+		sig := received
+
+		if received == unix.SIGTERM && stop.signal != nil {
+			sig = stop.signal
+		}
+
+		slog.Debug("Forwarding signal", "signal", sig, "received", received, "pid", child.Process.Pid)
+
+		if err := child.Process.Signal(sig); err != nil {
+			slog.Warn("Failed to forward signal", "signal", sig, "pid", child.Process.Pid, "error", err)
+		}
+
+		if received == unix.SIGTERM && stop.killTimeout > 0 && killTimer == nil {
+			killTimer = time.After(stop.killTimeout)
+		}
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig == unix.SIGTERM && stop.gracePeriod > 0 && graceTimer == nil && killTimer == nil {
+				slog.Debug("Delaying SIGTERM", "pid", child.Process.Pid, "grace_period", stop.gracePeriod)
+				graceTimer = time.After(stop.gracePeriod)
+				continue
+			}
+
+			// Other signals don't cancel SIGTERM pending during grace period.
+
+			if sig == unix.SIGTERM {
+				graceTimer = nil
+			}
+
+			forward(sig)
+
+		case <-graceTimer:
+			graceTimer = nil
+			forward(unix.SIGTERM)
+
+		case <-killTimer:
+			slog.Warn("Child did not exit in time, killing it", "pid", child.Process.Pid, "timeout", stop.killTimeout)
+
+			if err := child.Process.Kill(); err != nil {
+				slog.Warn("Failed to kill child", "pid", child.Process.Pid, "error", err)
+			}
+
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// Returns exit code of the finished child process given the error returned by
+// `Wait`. Follows shell convention of reporting 128+N for the process killed
+// by the signal N. Returns false if error does not describe the exit status.
+func childExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), true
+	}
+
+	return exitErr.ExitCode(), true
+}
@@ -0,0 +1,294 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestSuperviseChild(t *testing.T) {
+	newShellCommand := func(script string) *exec.Cmd {
+		return newChildCommand("/bin/sh", []string{"sh", "-c", script}, os.Environ())
+	}
+
+	t.Run("waits for child to exit", func(t *testing.T) {
+		err := superviseChild(newShellCommand("exit 0"), nil, stopPolicy{})
+
+		assert.Nil(t, err, "expected no error")
+	})
+
+	t.Run("returns child exit error", func(t *testing.T) {
+		err := superviseChild(newShellCommand("exit 3"), nil, stopPolicy{})
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 3, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("forwards signals to child", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+		}()
+
+		err := superviseChild(child, signals, stopPolicy{})
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 42, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("delays SIGTERM by grace period", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; while :; do sleep 0.01; done`)
+
+		signals <- unix.SIGTERM
+
+		started := time.Now()
+		err := superviseChild(child, signals, stopPolicy{gracePeriod: 200 * time.Millisecond})
+
+		assert.GreaterOrEqual(t, time.Since(started), 200*time.Millisecond)
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 42, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("forwards repeated SIGTERM during grace period immediately", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+		}()
+
+		started := time.Now()
+		err := superviseChild(child, signals, stopPolicy{gracePeriod: time.Minute})
+
+		assert.Less(t, time.Since(started), time.Minute)
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 42, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("translates SIGTERM into shutdown signal", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; trap "exit 43" INT; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+		}()
+
+		err := superviseChild(child, signals, stopPolicy{signal: unix.SIGINT})
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 43, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("forwards other signals as is", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; trap "exit 43" INT; trap "exit 44" HUP; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGHUP
+		}()
+
+		err := superviseChild(child, signals, stopPolicy{signal: unix.SIGINT})
+
+		if assert.IsType(t, &exec.ExitError{}, err) {
+			assert.Equal(t, 44, err.(*exec.ExitError).ExitCode())
+		}
+	})
+
+	t.Run("keeps SIGTERM pending when other signal is received during grace period", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "exit 42" TERM; trap "" INT; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+			time.Sleep(50 * time.Millisecond)
+			signals <- unix.SIGINT
+		}()
+
+		started := time.Now()
+		done := make(chan error, 1)
+
+		go func() {
+			done <- superviseChild(child, signals, stopPolicy{gracePeriod: 300 * time.Millisecond})
+		}()
+
+		select {
+		case err := <-done:
+			assert.GreaterOrEqual(t, time.Since(started), 400*time.Millisecond)
+
+			if assert.IsType(t, &exec.ExitError{}, err) {
+				assert.Equal(t, 42, err.(*exec.ExitError).ExitCode())
+			}
+
+		case <-time.After(2 * time.Second):
+			child.Process.Kill()
+			t.Error("expected child to receive SIGTERM after grace period")
+		}
+	})
+
+	t.Run("kills child that ignores SIGTERM after kill timeout", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		child := newShellCommand(`trap "" TERM; while :; do sleep 0.01; done`)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			signals <- unix.SIGTERM
+		}()
+
+		err := superviseChild(child, signals, stopPolicy{killTimeout: 100 * time.Millisecond})
+
+		code, ok := childExitCode(err)
+
+		assert.True(t, ok)
+		assert.Equal(t, 128+int(unix.SIGKILL), code)
+	})
+
+	t.Run("returns error when command can't be started", func(t *testing.T) {
+		err := superviseChild(newChildCommand("/nonexistent", []string{"nonexistent"}, nil), nil, stopPolicy{})
+
+		assert.NotNil(t, err, "expected an error")
+	})
+}
+
+func TestParseSignal(t *testing.T) {
+	t.Run("accepts names with or without SIG prefix", func(t *testing.T) {
+		for _, name := range []string{"TERM", "SIGTERM", "term", "sigterm"} {
+			sig, err := parseSignal(name)
+
+			assert.Nil(t, err, "expected no error")
+			assert.Equal(t, unix.SIGTERM, sig)
+		}
+	})
+
+	t.Run("fails on unknown signal", func(t *testing.T) {
+		_, err := parseSignal("WAZZUP")
+
+		assert.ErrorContains(t, err, `unknown signal "SIGWAZZUP"`)
+	})
+}
+
+func TestParseSignals(t *testing.T) {
+	t.Run("always includes SIGTERM", func(t *testing.T) {
+		signals, err := parseSignals([]string{"INT", "HUP", "SIGINT"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []os.Signal{unix.SIGTERM, unix.SIGINT, unix.SIGHUP}, signals)
+	})
+
+	t.Run("defaults to SIGTERM, SIGINT and SIGQUIT", func(t *testing.T) {
+		signals, err := parseSignals(forwardedSignals)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []os.Signal{unix.SIGTERM, unix.SIGINT, unix.SIGQUIT}, signals)
+	})
+
+	t.Run("rejects signals that can't be caught", func(t *testing.T) {
+		_, err := parseSignals([]string{"KILL"})
+
+		assert.ErrorContains(t, err, "SIGKILL can't be forwarded")
+	})
+}
+
+func TestChildExitCode(t *testing.T) {
+	run := func(script string) error {
+		return newChildCommand("/bin/sh", []string{"sh", "-c", script}, os.Environ()).Run()
+	}
+
+	t.Run("returns exit code of the child", func(t *testing.T) {
+		for _, expected := range []int{1, 2, 42, 255} {
+			code, ok := childExitCode(run(fmt.Sprintf("exit %d", expected)))
+
+			assert.True(t, ok)
+			assert.Equal(t, expected, code)
+		}
+	})
+
+	t.Run("returns 128+N when child is killed by signal N", func(t *testing.T) {
+		code, ok := childExitCode(run("kill -TERM $$"))
+
+		assert.True(t, ok)
+		assert.Equal(t, 128+int(unix.SIGTERM), code)
+
+		code, ok = childExitCode(run("kill -KILL $$"))
+
+		assert.True(t, ok)
+		assert.Equal(t, 128+int(unix.SIGKILL), code)
+	})
+
+	t.Run("returns false for non-exit errors", func(t *testing.T) {
+		_, ok := childExitCode(errors.New("wazzup"))
+
+		assert.False(t, ok)
+
+		_, ok = childExitCode(nil)
+
+		assert.False(t, ok)
+	})
+}
+
+func TestSuperviseCommand(t *testing.T) {
+	writeExecutable := func(t *testing.T, dir, script string) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "hello"), []byte("#!/bin/sh\n"+script), 0o755))
+	}
+
+	t.Run("runs command as given with --skip-lookpath", func(t *testing.T) {
+		cwd, path := t.TempDir(), t.TempDir()
+
+		writeExecutable(t, cwd, "exit 7")
+		writeExecutable(t, path, "exit 3")
+
+		t.Chdir(cwd)
+		t.Setenv("PATH", path)
+
+		err := superviseCommand("hello", []string{"hello"}, []string{"PATH=" + path}, nil, []os.Signal{unix.SIGHUP}, stopPolicy{})
+
+		code, ok := childExitCode(err)
+
+		assert.True(t, ok)
+		assert.Equal(t, 7, code, "expected command to be run from working directory rather than PATH")
+	})
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/ixti/fluent-bit-for-ecs/pkg/ecsmeta"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tagsFormat = "csv"
+	tagsKeys   []string
+)
+
+// tagsCmd represents the tags command
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "Prints ECS task metadata as AWS resource tags",
+	Long: `Prints ECS task metadata as tags named after lowercased metadata keys, e.g.
+AWS_REGION becomes aws_region. Tags with empty values are omitted.
+
+With --format csv (default) tags are printed as comma-separated key=value list,
+suitable for CloudWatch Logs output plugin. With --format json they are printed
+as an array of {"Key": ..., "Value": ...} objects.`,
+	Args: cobra.NoArgs,
+	RunE: tagsCmdRunE,
+}
+
+// Represents a single AWS resource tag.
+type tag struct {
+	Key   string
+	Value string
+}
+
+// Returns non-empty `environ` entries as tags named after lowercased keys. When
+// `keys` are given, only tags for those keys (case-insensitive) are returned.
+func environTags(environ []string, keys []string) ([]tag, error) {
+	for _, key := range keys {
+		if !slices.Contains(ecsmeta.EnvKeys, strings.ToUpper(key)) {
+			return nil, fmt.Errorf("unknown metadata key %q", key)
+		}
+	}
+
+	tags := []tag{}
+
+	for _, v := range environ {
+		key, value, _ := strings.Cut(v, "=")
+
+		if value == "" {
+			continue
+		}
+
+		if len(keys) > 0 && !slices.ContainsFunc(keys, func(k string) bool { return strings.EqualFold(k, key) }) {
+			continue
+		}
+
+		tags = append(tags, tag{Key: strings.ToLower(key), Value: value})
+	}
+
+	return tags, nil
+}
+
+// Writes `tags` in the given `format` (either csv or json).
+func writeTags(w io.Writer, format string, tags []tag) error {
+	switch format {
+	case "csv":
+		pairs := make([]string, len(tags))
+
+		for i, t := range tags {
+			pairs[i] = t.Key + "=" + t.Value
+		}
+
+		_, err := fmt.Fprintln(w, strings.Join(pairs, ","))
+
+		return err
+
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(tags)
+
+	default:
+		return fmt.Errorf("unsupported format %q (expected csv or json)", format)
+	}
+}
+
+func tagsCmdRunE(cmd *cobra.Command, args []string) error {
+	metadata, err := getEcsTaskMetadata(cmd.Context(), newHTTPClient())
+
+	if err != nil {
+		slog.Error("Can't retrieve ECS task metadata", "error", err)
+		return err
+	}
+
+	slog.SetDefault(withMetadataLogAttrs(slog.Default(), logFormat, metadata))
+
+	tags, err := environTags(metadata.Environ(), tagsKeys)
+
+	if err != nil {
+		return err
+	}
+
+	return writeTags(cmd.OutOrStdout(), tagsFormat, tags)
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+
+	addMetadataEndpointFlags(tagsCmd)
+	addMetadataCacheFlags(tagsCmd)
+	addTemplateFlags(tagsCmd)
+	addEnrichFlags(tagsCmd)
+	addClusterArnStyleFlag(tagsCmd)
+	tagsCmd.Flags().StringVar(&tagsFormat, "format", tagsFormat, "Output format: csv or json")
+	tagsCmd.Flags().StringSliceVar(&tagsKeys, "keys", nil, "Comma-separated metadata keys to print (e.g. ECS_CLUSTER_NAME,ECS_TASK_ID), all by default")
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironTags(t *testing.T) {
+	environ := []string{"AWS_REGION=aws-region-1", "ECS_SERVICE_NAME=", "ECS_TASK_ID=deadbeef"}
+
+	t.Run("returns non-empty tags", func(t *testing.T) {
+		tags, err := environTags(environ, nil)
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []tag{{"aws_region", "aws-region-1"}, {"ecs_task_id", "deadbeef"}}, tags)
+	})
+
+	t.Run("returns selected tags only", func(t *testing.T) {
+		tags, err := environTags(environ, []string{"ecs_task_id", "ECS_SERVICE_NAME"})
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, []tag{{"ecs_task_id", "deadbeef"}}, tags)
+	})
+
+	t.Run("returns error on unknown key", func(t *testing.T) {
+		tags, err := environTags(environ, []string{"ECS_NOPE"})
+
+		assert.ErrorContains(t, err, `unknown metadata key "ECS_NOPE"`)
+		assert.Nil(t, tags)
+	})
+}
+
+func TestWriteTags(t *testing.T) {
+	tags := []tag{{"aws_region", "aws-region-1"}, {"ecs_task_id", "deadbeef"}}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeTags(&buf, "csv", tags), "expected no error")
+		assert.Equal(t, "aws_region=aws-region-1,ecs_task_id=deadbeef\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeTags(&buf, "json", tags), "expected no error")
+		assert.JSONEq(t, `[{"Key":"aws_region","Value":"aws-region-1"},{"Key":"ecs_task_id","Value":"deadbeef"}]`, buf.String())
+	})
+
+	t.Run("json without tags", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeTags(&buf, "json", []tag{}), "expected no error")
+		assert.JSONEq(t, `[]`, buf.String())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NotNil(t, writeTags(&buf, "yaml", tags), "expected an error")
+		assert.Empty(t, buf.String())
+	})
+}
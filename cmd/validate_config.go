@@ -0,0 +1,227 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateConfigPath   = "/fluent-bit/etc/fluent-bit.yml"
+	validateConfigBinary = "/fluent-bit/bin/fluent-bit"
+)
+
+// Returned when Fluent-Bit binary doesn't support `--dry-run`.
+var errDryRunUnsupported = errors.New("Fluent-Bit doesn't support --dry-run")
+
+// validateConfigCmd represents the validate-config command
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config",
+	Short: "Validates Fluent-Bit configuration file",
+	Long: `Validates Fluent-Bit configuration file with "fluent-bit --dry-run", e.g. before
+triggering hot reload of the running instance. Fluent-Bit HTTP API can only
+reload the configuration it was started with, and does so for real, so it's not
+suitable for validation.
+
+Errors are printed along with the offending lines of the configuration files
+(including ones pulled in with includes), when Fluent-Bit reports them along
+with the file name. If Fluent-Bit is too old to support --dry-run, a
+warning is logged and the configuration is assumed to be valid.`,
+	Args: cobra.NoArgs,
+	RunE: validateConfigCmdRunE,
+}
+
+// Matches file names and line numbers in Fluent-Bit config errors, e.g.
+// `fluent-bit.conf:12` of classic format, or `file "fluent-bit.yml", line 12`
+// of YAML. Bare `line 12` is not matched, as it doesn't tell which file.
+var configErrorLinePattern = regexp.MustCompile(`([^\s"':]+\.(?:conf|ya?ml))"?:(\d+)|\bfile "([^"]+)", line (\d+)`)
+
+// Matches complaints of Fluent-Bit about unsupported command line option.
+var unsupportedOptionPattern = regexp.MustCompile(`(?i)(unrecognized|invalid|unknown) option`)
+
+// Runs Fluent-Bit `binary` in dry-run mode against `config` file. Returns
+// combined output of Fluent-Bit, and an error if the config is invalid.
+func dryRunFluentBit(binary, config string) (string, error) {
+	var output bytes.Buffer
+
+	cmd := exec.Command(binary, "--dry-run", "-c", config)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		if strings.Contains(output.String(), "--dry-run") && unsupportedOptionPattern.MatchString(output.String()) {
+			return output.String(), errDryRunUnsupported
+		}
+
+		return output.String(), fmt.Errorf("invalid config %s: %w", config, err)
+	}
+
+	return output.String(), err
+}
+
+// Returns sorted unique line numbers referenced by Fluent-Bit error `output`,
+// grouped by file name as reported by Fluent-Bit.
+func configErrorLines(output string) map[string][]int {
+	lines := map[string][]int{}
+
+	for _, match := range configErrorLinePattern.FindAllStringSubmatch(output, -1) {
+		file := match[1] + match[3]
+		n, err := strconv.Atoi(match[2] + match[4])
+
+		if err == nil && n > 0 && !slices.Contains(lines[file], n) {
+			lines[file] = append(lines[file], n)
+		}
+	}
+
+	for _, fileLines := range lines {
+		slices.Sort(fileLines)
+	}
+
+	return lines
+}
+
+// Returns path of the config `file` reported by Fluent-Bit, which is relative
+// to the directory of the main `config` file, unless it's absolute.
+func resolveConfigFile(config, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+
+	return filepath.Join(filepath.Dir(config), file)
+}
+
+// Writes lines referenced by Fluent-Bit error `output`, each file's lines
+// under its path. Files that can't be read are skipped.
+func writeConfigErrorContext(w io.Writer, config, output string) error {
+	lines := configErrorLines(output)
+
+	for _, file := range slices.Sorted(maps.Keys(lines)) {
+		path := resolveConfigFile(config, file)
+
+		if _, err := os.Stat(path); err != nil {
+			slog.Warn("Can't read Fluent-Bit config", "path", path, "error", err)
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "%s:\n", path); err != nil {
+			return err
+		}
+
+		if err := writeConfigLines(w, path, lines[file]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes given `lines` of the `config` file prefixed with their numbers.
+// Lines past the end of the file are skipped.
+func writeConfigLines(w io.Writer, config string, lines []int) error {
+	f, err := os.Open(config)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan() && len(lines) > 0; n++ {
+		if n != lines[0] {
+			continue
+		}
+
+		lines = lines[1:]
+
+		if _, err := fmt.Fprintf(w, "%5d | %s\n", n, scanner.Text()); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func validateConfigCmdRunE(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(validateConfigPath); err != nil {
+		slog.Error("Can't read Fluent-Bit config", "path", validateConfigPath, "error", err)
+		return err
+	}
+
+	output, err := dryRunFluentBit(validateConfigBinary, validateConfigPath)
+
+	if errors.Is(err, errDryRunUnsupported) {
+		slog.Warn("Can't validate Fluent-Bit config, skipping", "path", validateConfigPath, "error", err)
+		return nil
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+
+		if !errors.As(err, &exitErr) {
+			slog.Error("Can't run Fluent-Bit", "binary", validateConfigBinary, "error", err)
+			return err
+		}
+
+		if _, err := io.WriteString(cmd.ErrOrStderr(), output); err != nil {
+			return err
+		}
+
+		if err := writeConfigErrorContext(cmd.ErrOrStderr(), validateConfigPath, output); err != nil {
+			return err
+		}
+
+		slog.Error("Fluent-Bit config is invalid", "path", validateConfigPath)
+		return err
+	}
+
+	slog.Info("Fluent-Bit config is valid", "path", validateConfigPath)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(validateConfigCmd)
+
+	validateConfigCmd.Flags().StringVarP(&validateConfigPath, "config", "c", validateConfigPath, "Fluent-Bit configuration file to validate")
+	validateConfigCmd.Flags().StringVar(&validateConfigBinary, "fluent-bit", validateConfigBinary, "Fluent-Bit binary to validate configuration with")
+}
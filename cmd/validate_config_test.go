@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunFluentBit(t *testing.T) {
+	writeFluentBit := func(t *testing.T, script string) string {
+		path := filepath.Join(t.TempDir(), "fluent-bit")
+		assert.Nil(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+
+		return path
+	}
+
+	t.Run("passes config to fluent-bit --dry-run", func(t *testing.T) {
+		output, err := dryRunFluentBit(writeFluentBit(t, `echo "$@"`), "/fluent-bit/etc/fluent-bit.yml")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "--dry-run -c /fluent-bit/etc/fluent-bit.yml\n", output)
+	})
+
+	t.Run("fails on invalid config", func(t *testing.T) {
+		output, err := dryRunFluentBit(writeFluentBit(t, "echo '[config] error in fluent-bit.conf:3: undefined value' >&2\nexit 1"), "fluent-bit.conf")
+
+		var exitErr *exec.ExitError
+
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Contains(t, output, "undefined value")
+	})
+
+	t.Run("detects lack of --dry-run support", func(t *testing.T) {
+		_, err := dryRunFluentBit(writeFluentBit(t, "echo \"fluent-bit: unrecognized option '--dry-run'\" >&2\nexit 1"), "fluent-bit.conf")
+
+		assert.ErrorIs(t, err, errDryRunUnsupported)
+	})
+
+	t.Run("fails when fluent-bit is missing", func(t *testing.T) {
+		_, err := dryRunFluentBit(filepath.Join(t.TempDir(), "fluent-bit"), "fluent-bit.conf")
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestConfigErrorLines(t *testing.T) {
+	t.Run("returns line numbers of classic config errors", func(t *testing.T) {
+		output := "[error] [config] error in /fluent-bit/etc/fluent-bit.conf:12: undefined value\n[error] [config] error in /fluent-bit/etc/fluent-bit.conf:3: invalid indentation"
+
+		assert.Equal(t, map[string][]int{"/fluent-bit/etc/fluent-bit.conf": {3, 12}}, configErrorLines(output))
+	})
+
+	t.Run("returns line numbers of YAML config errors", func(t *testing.T) {
+		output := `[error] [config] YAML error found in file "/fluent-bit/etc/fluent-bit.yml", line 7, column 3: did not find expected key`
+
+		assert.Equal(t, map[string][]int{"/fluent-bit/etc/fluent-bit.yml": {7}}, configErrorLines(output))
+	})
+
+	t.Run("groups line numbers by file", func(t *testing.T) {
+		output := "error in outputs.yml:4: invalid value\nerror in filters.yml:2: unknown key\nerror in outputs.yml:1: missing name"
+
+		assert.Equal(t, map[string][]int{"outputs.yml": {1, 4}, "filters.yml": {2}}, configErrorLines(output))
+	})
+
+	t.Run("returns nothing when there are no file names", func(t *testing.T) {
+		assert.Empty(t, configErrorLines("[error] configuration file contains errors"))
+		assert.Empty(t, configErrorLines("[error] parse error at line 7"))
+	})
+}
+
+func TestWriteConfigErrorContext(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "fluent-bit.yml")
+
+	assert.Nil(t, os.WriteFile(config, []byte("includes:\n  - outputs.yml\n"), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "outputs.yml"), []byte("pipeline:\n  outputs:\n    - nme: null\n"), 0o600))
+
+	t.Run("prints lines of included file the error refers to", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeConfigErrorContext(&buf, config, "[error] error in outputs.yml:3: unknown key"))
+		assert.Equal(t, filepath.Join(dir, "outputs.yml")+":\n    3 |     - nme: null\n", buf.String())
+	})
+
+	t.Run("prints nothing without file name", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeConfigErrorContext(&buf, config, "[error] parse error at line 1"))
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("skips missing files", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeConfigErrorContext(&buf, config, "[error] error in missing.yml:1: unknown key"))
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestWriteConfigLines(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	assert.Nil(t, os.WriteFile(config, []byte("[SERVICE]\n    Flush 1\n  Log_Level\n"), 0o600))
+
+	var buf bytes.Buffer
+
+	assert.Nil(t, writeConfigLines(&buf, config, []int{1, 3, 42}))
+	assert.Equal(t, "    1 | [SERVICE]\n    3 |   Log_Level\n", buf.String())
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Set at build time, e.g.:
+//
+//	go build -ldflags "-X github.com/ixti/fluent-bit-for-ecs/cmd.version=1.0.0"
+var version = "dev"
+
+var versionJSON bool
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Prints build version information",
+	Args:  cobra.NoArgs,
+	RunE:  versionCmdRunE,
+}
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+func getVersionInfo() versionInfo {
+	info := versionInfo{Version: version, GoVersion: runtime.Version()}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+func (v versionInfo) String() string {
+	revision := firstNonEmpty(v.Revision, "unknown")
+
+	if v.Modified {
+		revision += "-dirty"
+	}
+
+	return fmt.Sprintf("%s (revision %s, %s)", v.Version, revision, v.GoVersion)
+}
+
+func writeVersionInfo(w io.Writer, info versionInfo, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+
+	_, err := fmt.Fprintln(w, info.String())
+
+	return err
+}
+
+func versionCmdRunE(cmd *cobra.Command, args []string) error {
+	return writeVersionInfo(cmd.OutOrStdout(), getVersionInfo(), versionJSON)
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.Version = getVersionInfo().String()
+
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print version information as JSON")
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteVersionInfo(t *testing.T) {
+	info := versionInfo{Version: "1.2.3", Revision: "deadbeef", GoVersion: "go1.24.3"}
+
+	t.Run("prints human readable version", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeVersionInfo(&buf, info, false), "expected no error")
+		assert.Equal(t, "1.2.3 (revision deadbeef, go1.24.3)\n", buf.String())
+	})
+
+	t.Run("prints unknown revision", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeVersionInfo(&buf, versionInfo{Version: "dev", GoVersion: "go1.24.3"}, false), "expected no error")
+		assert.Equal(t, "dev (revision unknown, go1.24.3)\n", buf.String())
+	})
+
+	t.Run("prints JSON version", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeVersionInfo(&buf, info, true), "expected no error")
+		assert.JSONEq(t, `{"version":"1.2.3","revision":"deadbeef","go_version":"go1.24.3"}`, buf.String())
+	})
+}
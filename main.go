@@ -22,16 +22,9 @@ THE SOFTWARE.
 package main
 
 import (
-	"log/slog"
-	"os"
-
 	"github.com/ixti/fluent-bit-for-ecs/cmd"
 )
 
 func main() {
-	if os.Getenv("FLUENT_BIT_FOR_ECS_DEBUG") == "1" {
-		slog.SetLogLoggerLevel(slog.LevelDebug)
-	}
-
 	cmd.Execute()
 }
@@ -0,0 +1,510 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package ecsmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// Environment variable ECS sets to the metadata endpoint URI of the container.
+const EndpointEnvVar = "ECS_CONTAINER_METADATA_URI_V4"
+
+// Path of the task metadata document, relative to the endpoint URI.
+const DefaultTaskPath = "/task"
+
+var (
+	// Metadata endpoint URI is not configured, i.e. not running in ECS.
+	ErrEndpointNotSet = errors.New("ECS task metadata endpoint is not set")
+
+	// Metadata endpoint URI is malformed, endpoint could not be reached, timed
+	// out, or responded with non-2xx status.
+	ErrMetadataUnavailable = errors.New("ECS task metadata is unavailable")
+
+	// Metadata endpoint responded with something that is not a valid JSON
+	// document.
+	ErrMetadataDecode = errors.New("ECS task metadata is malformed")
+)
+
+// Client retrieves metadata from the ECS task metadata endpoint. Zero value is
+// usable, but doesn't retry failed requests.
+type Client struct {
+	HTTPClient *http.Client  // HTTP client to perform requests with, http.DefaultClient if nil
+	Endpoint   string        // Metadata endpoint URI
+	TaskPath   string        // Path of the task metadata document, DefaultTaskPath if empty
+	Header     http.Header   // Extra headers sent with each request, e.g. for authenticating proxies
+	Retries    int           // Number of retries of transient failures
+	RetryDelay time.Duration // Delay before the first retry, doubled after each
+	Jitter     bool          // Sleep random duration up to the delay instead ("full jitter")
+	MaxElapsed time.Duration // Time limit of all attempts, after which retries stop (0 for no limit)
+	Timeout    time.Duration // Time limit of a single request (0 for no limit)
+
+	// Log fields of metadata documents that are not among known v4 fields, to
+	// notice changes of the documents' shape (e.g. after ECS agent upgrade).
+	StrictSchema bool
+}
+
+// Returns new client of the endpoint given by ECS_CONTAINER_METADATA_URI_V4
+// environment variable.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{},
+		Endpoint:   os.Getenv(EndpointEnvVar),
+		TaskPath:   DefaultTaskPath,
+		Retries:    3,
+		RetryDelay: 100 * time.Millisecond,
+		Jitter:     true,
+		MaxElapsed: 10 * time.Second,
+		Timeout:    2 * time.Second,
+	}
+}
+
+// Returns random duration in [0, d), spreading retries of many tasks started
+// at once, so that they don't hit the endpoint in lockstep.
+var jitter = func(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d)
+}
+
+// Retrieves task metadata using the default client.
+func Fetch(ctx context.Context) (*Metadata, error) {
+	return NewClient().Fetch(ctx)
+}
+
+// Performs single metadata request. Returns whenever failure is transient and
+// the request is worth retrying along with the error.
+func (c *Client) do(ctx context.Context, url string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrMetadataUnavailable, err)
+	}
+
+	for name, values := range c.Header {
+		req.Header[name] = values
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %w", ErrMetadataUnavailable, err)
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %w", ErrMetadataUnavailable, err)
+	}
+
+	// Some error responses carry valid-looking JSON (e.g. 403 with an error
+	// document), so anything but 2xx must not be decoded as metadata.
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, res.StatusCode >= 500, fmt.Errorf("%w: unexpected status from metadata endpoint: %s (body: %q)", ErrMetadataUnavailable, res.Status, bodySnippet(body))
+	}
+
+	if contentType := res.Header.Get("Content-Type"); !isJSONContentType(contentType) {
+		return nil, false, fmt.Errorf("%w: unexpected content type from metadata endpoint: %q (body: %q)", ErrMetadataDecode, contentType, bodySnippet(body))
+	}
+
+	return body, false, nil
+}
+
+// Tells whether the Content-Type header denotes a JSON document. Missing header
+// is given the benefit of the doubt.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// Returns the beginning of the response body, to be included in error messages.
+func bodySnippet(body []byte) string {
+	const maxLen = 128
+
+	if len(body) > maxLen {
+		return string(body[:maxLen]) + "..."
+	}
+
+	return string(body)
+}
+
+// Fetches document at `path` of the metadata endpoint (e.g. `/task`) as is.
+// Each attempt is bounded by Timeout, if set. Connection errors, timeouts and 5xx
+// responses are retried up to Retries times with exponential backoff (with
+// full jitter if Jitter is set), unless MaxElapsed would be exceeded, while
+// other failures are returned immediately.
+func (c *Client) FetchRaw(ctx context.Context, path string) ([]byte, error) {
+	if c.Endpoint == "" {
+		return nil, ErrEndpointNotSet
+	}
+
+	url := joinEndpointPath(c.Endpoint, path)
+	delay := c.RetryDelay
+	started := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		body, retryable, err := c.attempt(ctx, url)
+
+		if err == nil {
+			return body, nil
+		}
+
+		if !retryable || attempt >= c.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		sleep := delay
+
+		if c.Jitter {
+			sleep = jitter(delay)
+		}
+
+		if elapsed := time.Since(started); c.MaxElapsed > 0 && elapsed+sleep > c.MaxElapsed {
+			slog.Debug("Giving up ECS metadata request", "url", url, "attempt", attempt+1, "elapsed", elapsed, "max_elapsed", c.MaxElapsed)
+			return nil, err
+		}
+
+		slog.Debug("Retrying ECS metadata request", "url", url, "attempt", attempt+1, "delay", sleep, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrMetadataUnavailable, ctx.Err())
+		case <-time.After(sleep):
+			delay *= 2
+		}
+	}
+}
+
+// Performs single metadata request bounded by Timeout, unless it's not set.
+func (c *Client) attempt(ctx context.Context, url string) ([]byte, bool, error) {
+	if c.Timeout <= 0 {
+		return c.do(ctx, url)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	return c.do(ctx, url)
+}
+
+// Fields of the task metadata document (v4), including those this package
+// doesn't use.
+var taskDocumentFields = []string{
+	"Cluster",
+	"ServiceName",
+	"VPCID",
+	"TaskARN",
+	"Family",
+	"Revision",
+	"DesiredStatus",
+	"KnownStatus",
+	"Limits",
+	"PullStartedAt",
+	"PullStoppedAt",
+	"ExecutionStoppedAt",
+	"AvailabilityZone",
+	"LaunchType",
+	"Containers",
+	"TaskTags",
+	"ContainerInstanceTags",
+	"EphemeralStorageMetrics",
+	"ClockDrift",
+	"Errors",
+	"FaultInjectionEnabled",
+	"TaskNetworkConfig",
+}
+
+// Fields of the container metadata document (v4), also found in the list of
+// the task's containers, including those this package doesn't use.
+var containerDocumentFields = []string{
+	"DockerId",
+	"Name",
+	"DockerName",
+	"Image",
+	"ImageID",
+	"Labels",
+	"DesiredStatus",
+	"KnownStatus",
+	"Limits",
+	"CreatedAt",
+	"StartedAt",
+	"FinishedAt",
+	"Type",
+	"Networks",
+	"ContainerARN",
+	"ContainerInstanceARN",
+	"LogOptions",
+	"LogDriver",
+	"Health",
+	"Volumes",
+	"Ports",
+	"RestartCount",
+	"ExitCode",
+	"Snapshotter",
+}
+
+// Returns keys of JSON object that are not among `known` ones, sorted.
+func unknownFields(object map[string]json.RawMessage, known []string) []string {
+	var unknown []string
+
+	for key := range object {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+
+	slices.Sort(unknown)
+
+	return unknown
+}
+
+// Returns fields of the task or container metadata document, that are not
+// known to be part of it, e.g. `Wazzup` or `Containers[0].Wazzup`.
+func unknownDocumentFields(body []byte, v any) ([]string, error) {
+	var document map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	if _, ok := v.(*Container); ok {
+		return unknownFields(document, containerDocumentFields), nil
+	}
+
+	unknown := unknownFields(document, taskDocumentFields)
+
+	var containers []map[string]json.RawMessage
+
+	if raw, ok := document["Containers"]; ok {
+		if err := json.Unmarshal(raw, &containers); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, container := range containers {
+		for _, field := range unknownFields(container, containerDocumentFields) {
+			unknown = append(unknown, fmt.Sprintf("Containers[%d].%s", i, field))
+		}
+	}
+
+	return unknown, nil
+}
+
+// Returns URL of the document at `path` relative to the `endpoint`, with
+// exactly one slash between them. Empty `path` refers to the endpoint itself.
+func joinEndpointPath(endpoint, path string) string {
+	if path == "" {
+		return endpoint
+	}
+
+	return strings.TrimRight(endpoint, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// Fetches JSON document at `path` of the metadata endpoint and decodes it
+// into `v`.
+func (c *Client) fetchDocument(ctx context.Context, path string, v any) error {
+	body, err := c.FetchRaw(ctx, path)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Retrieved ECS metadata document", "path", path, "body", string(body))
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: %w (body: %q)", ErrMetadataDecode, err, bodySnippet(body))
+	}
+
+	if c.StrictSchema {
+		if unknown, err := unknownDocumentFields(body, v); err != nil {
+			slog.Warn("ECS metadata document has unexpected shape", "path", path, "error", err)
+		} else if len(unknown) > 0 {
+			slog.Warn("ECS metadata document has unknown fields", "path", path, "fields", unknown)
+		}
+	}
+
+	return nil
+}
+
+// Retrieves task metadata, and derives from it values that are not given
+// explicitly (e.g. AWS region and account from the task ARN).
+//
+// Returned errors wrap one of ErrEndpointNotSet, ErrMetadataUnavailable or
+// ErrMetadataDecode.
+func (c *Client) Fetch(ctx context.Context) (*Metadata, error) {
+	if c.Endpoint == "" {
+		return nil, ErrEndpointNotSet
+	}
+
+	// Container document tells which of the task's containers is the current
+	// one, and the container instance, for tasks running on EC2. It is not
+	// essential, so any failure to retrieve it is non-fatal, and it's not
+	// retried, so that retries budget is left for the task document.
+
+	container := &Container{}
+	once := *c
+	once.Retries = 0
+
+	if err := once.fetchDocument(ctx, "", container); err != nil {
+		slog.Warn("Failed to retrieve ECS container metadata", "error", err)
+	}
+
+	task := &taskDocument{}
+
+	taskPath := c.TaskPath
+
+	if taskPath == "" {
+		taskPath = DefaultTaskPath
+	}
+
+	if err := c.fetchDocument(ctx, taskPath, task); err != nil {
+		return nil, err
+	}
+
+	return resolveMetadata(task, container), nil
+}
+
+// Reads task metadata document from the file at `path` instead of the
+// endpoint, e.g. for testing outside of ECS, and derives values the same way
+// Fetch does. Container document is not available, so per-container values
+// are left empty.
+//
+// Returned errors wrap one of ErrMetadataUnavailable or ErrMetadataDecode.
+func ReadFile(path string) (*Metadata, error) {
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMetadataUnavailable, err)
+	}
+
+	slog.Debug("Read ECS metadata document", "path", path, "body", string(body))
+
+	task := &taskDocument{}
+
+	if err := json.Unmarshal(body, task); err != nil {
+		return nil, fmt.Errorf("%w: %w (body: %q)", ErrMetadataDecode, err, bodySnippet(body))
+	}
+
+	return resolveMetadata(task, &Container{}), nil
+}
+
+// Merges task document and the current container's document into metadata.
+// Task-level values come from the task document, and container-level ones
+// from the container document, falling back to the task's entry of the current
+// container. Values that are not given explicitly are derived from the rest.
+func resolveMetadata(task *taskDocument, container *Container) *Metadata {
+	metadata := &task.Metadata
+
+	// Extract Task ID, AWS Partition, Region and Account ID from Task ARN
+
+	taskARN, err := arn.Parse(metadata.EcsTaskARN)
+
+	if err != nil {
+		slog.Error("Failed to parse ECS Task ARN", "arn", metadata.EcsTaskARN, "error", err)
+	} else {
+		metadata.AwsRegion = taskARN.Region
+		metadata.AwsAccountID = taskARN.AccountID
+		metadata.AwsPartition = taskARN.Partition
+		metadata.EcsTaskID = lastArnPart(taskARN)
+		metadata.EcsTaskDefinitionARN = taskDefinitionArn(taskARN, metadata.EcsTaskFamily, metadata.EcsTaskRevision)
+
+		// Some agent versions omit the Cluster field.
+
+		if metadata.EcsClusterName == "" {
+			metadata.EcsClusterName = clusterNameFromTaskArn(taskARN)
+		}
+	}
+
+	// Per documentation, the Cluster field can be either an ARN or a short name.
+	// Cluster names can't contain slashes, so one that does and yet is not a
+	// valid ARN means the response is malformed. It's left intact though.
+
+	if strings.Contains(metadata.EcsClusterName, "/") {
+		clusterARN, err := arn.Parse(metadata.EcsClusterName)
+
+		if err != nil {
+			slog.Error("Malformed ECS Cluster: neither a valid ARN nor a cluster name, leaving it as is", "cluster", metadata.EcsClusterName, "error", err)
+		} else {
+			metadata.EcsClusterARN = metadata.EcsClusterName
+			metadata.EcsClusterName = lastArnPart(clusterARN)
+		}
+	} else {
+		// Cluster shares partition, region and account with its tasks.
+
+		metadata.EcsClusterARN = clusterArn(taskARN, metadata.EcsClusterName)
+	}
+
+	if container.ContainerInstanceARN != "" {
+		metadata.EcsContainerInstanceARN = container.ContainerInstanceARN
+	}
+
+	// Per-container fields are left empty, unless the current container is
+	// known from its own document or found among the task's containers:
+	// guessing might mislabel records.
+
+	if container.Name != "" {
+		metadata.EcsContainerName = container.Name
+		metadata.EcsImage = container.Image
+		metadata.EcsImageDigest = imageDigest(container.ImageID)
+	} else if current := findCurrentContainer(task.Containers, container.DockerID); current != nil {
+		metadata.EcsContainerName = current.Name
+		metadata.EcsImage = current.Image
+		metadata.EcsImageDigest = imageDigest(current.ImageID)
+	} else if len(task.Containers) > 0 {
+		slog.Warn("Failed to find current container among ECS task containers", "docker_id", container.DockerID)
+	}
+
+	// Current container's address is preferred, for tasks not in awsvpc mode.
+
+	metadata.EcsTaskIP = firstIPv4Address(append([]Container{*container}, task.Containers...)...)
+
+	return metadata
+}
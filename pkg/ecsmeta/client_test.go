@@ -0,0 +1,1034 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package ecsmeta
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns client of the given endpoint, that doesn't linger between retries.
+func newTestClient(endpoint string) *Client {
+	client := NewClient()
+	client.Endpoint = endpoint
+	client.RetryDelay = time.Millisecond
+
+	return client
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		d := jitter(time.Second)
+
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+
+	assert.Equal(t, time.Duration(0), jitter(0))
+}
+
+func TestClient_FetchRaw(t *testing.T) {
+	fakeFlakyServer := func(t *testing.T, failures int32, failureStatusCode int) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= failures {
+				w.WriteHeader(failureStatusCode)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server, &calls
+	}
+
+	t.Run("retries 5xx responses until success", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+		client := newTestClient(server.URL)
+		client.Retries = 3
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "{}", string(body))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("gives up after configured number of retries", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 5, http.StatusInternalServerError)
+
+		client := newTestClient(server.URL)
+		client.Retries = 2
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, body)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("stops retrying once max elapsed time is exceeded", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 100, http.StatusInternalServerError)
+
+		client := newTestClient(server.URL)
+		client.Retries = 100
+		client.RetryDelay = 20 * time.Millisecond
+		client.Jitter = false
+		client.MaxElapsed = 100 * time.Millisecond
+
+		started := time.Now()
+		_, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.Less(t, time.Since(started), 200*time.Millisecond)
+
+		// After delays of 20ms and 40ms, another one of 80ms would exceed 100ms.
+
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("sleeps random duration up to delay with jitter", func(t *testing.T) {
+		var delays []time.Duration
+
+		oldJitter := jitter
+
+		t.Cleanup(func() { jitter = oldJitter })
+
+		jitter = func(d time.Duration) time.Duration {
+			delays = append(delays, d)
+			return 0
+		}
+
+		server, calls := fakeFlakyServer(t, 3, http.StatusInternalServerError)
+
+		client := newTestClient(server.URL)
+		client.Retries = 3
+		client.RetryDelay = time.Minute
+
+		_, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, int32(4), calls.Load())
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}, delays)
+	})
+
+	t.Run("does not retry 4xx responses", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 1, http.StatusNotFound)
+
+		client := newTestClient(server.URL)
+		client.Retries = 3
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.NotNil(t, err, "expected an error")
+		assert.Nil(t, body)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("returns error with status and body on non-2xx responses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"Cluster": "cluster-name"}`))
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL)
+		client.Retries = 3
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.ErrorContains(t, err, "403 Forbidden")
+		assert.ErrorContains(t, err, `{\"Cluster\": \"cluster-name\"}`)
+		assert.Nil(t, body)
+	})
+
+	t.Run("sends configured headers", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer deadbeef", r.Header.Get("Authorization"))
+			assert.Equal(t, "cafebabe", r.Header.Get("X-Aws-Ec2-Metadata-Token"))
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL)
+		client.Header = http.Header{}
+		client.Header.Set("Authorization", "Bearer deadbeef")
+		client.Header.Set("X-aws-ec2-metadata-token", "cafebabe")
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "{}", string(body))
+	})
+
+	t.Run("returns error when endpoint is unreachable", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 0, http.StatusOK)
+		server.Close()
+
+		client := newTestClient(server.URL)
+		client.Retries = 1
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.Nil(t, body)
+		assert.Equal(t, int32(0), calls.Load())
+	})
+
+	t.Run("times out when endpoint hangs", func(t *testing.T) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL)
+		client.Retries = 1
+		client.Timeout = 10 * time.Millisecond
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, body)
+		assert.Equal(t, int32(2), calls.Load(), "expected timed out request to be retried")
+	})
+
+	t.Run("does not limit requests when timeout is not set", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL)
+		client.Timeout = 0
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{}`), body)
+	})
+
+	t.Run("fails without retries when endpoint is malformed", func(t *testing.T) {
+		client := newTestClient("http://[::1")
+		client.Retries = 3
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.Nil(t, body)
+	})
+
+	t.Run("works with zero value client", func(t *testing.T) {
+		server, calls := fakeFlakyServer(t, 0, http.StatusOK)
+		client := Client{Endpoint: server.URL}
+
+		body, err := client.FetchRaw(context.Background(), "/task")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(`{}`), body)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestUnknownDocumentFields(t *testing.T) {
+	t.Run("returns nothing for known fields", func(t *testing.T) {
+		unknown, err := unknownDocumentFields([]byte(`{"DockerId": "cafebabe", "Name": "log_router", "Labels": {}, "Ports": []}`), &Container{})
+
+		assert.Nil(t, err)
+		assert.Empty(t, unknown)
+	})
+
+	t.Run("returns all unknown fields of container document", func(t *testing.T) {
+		unknown, err := unknownDocumentFields([]byte(`{"DockerId": "cafebabe", "Wazzup": true, "Foo": 1}`), &Container{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Foo", "Wazzup"}, unknown)
+	})
+
+	t.Run("returns unknown fields of task document and its containers", func(t *testing.T) {
+		body := `{
+			"Cluster": "cluster-name",
+			"TaskTags": {},
+			"Wazzup": true,
+			"Containers": [
+				{ "DockerId": "cafebabe", "Health": {} },
+				{ "DockerId": "deadbeef", "Wazzup": true }
+			]
+		}`
+
+		unknown, err := unknownDocumentFields([]byte(body), &taskDocument{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Wazzup", "Containers[1].Wazzup"}, unknown)
+	})
+
+	t.Run("returns error when document is not an object", func(t *testing.T) {
+		_, err := unknownDocumentFields([]byte(`[]`), &Container{})
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestJoinEndpointPath(t *testing.T) {
+	t.Run("joins endpoint and path with single slash", func(t *testing.T) {
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef/task", joinEndpointPath("http://169.254.170.2/v4/deadbeef", "/task"))
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef/task", joinEndpointPath("http://169.254.170.2/v4/deadbeef/", "/task"))
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef/task", joinEndpointPath("http://169.254.170.2/v4/deadbeef", "task"))
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef/task", joinEndpointPath("http://169.254.170.2/v4/deadbeef/", "task"))
+	})
+
+	t.Run("keeps nested paths", func(t *testing.T) {
+		assert.Equal(t, "http://localhost:8080/v4/task/stats", joinEndpointPath("http://localhost:8080/v4", "/task/stats"))
+	})
+
+	t.Run("returns endpoint as is when path is empty", func(t *testing.T) {
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef", joinEndpointPath("http://169.254.170.2/v4/deadbeef", ""))
+		assert.Equal(t, "http://169.254.170.2/v4/deadbeef/", joinEndpointPath("http://169.254.170.2/v4/deadbeef/", ""))
+	})
+}
+
+func TestClient_Fetch(t *testing.T) {
+	fakeEcsMetadataServer := func(t *testing.T, statusCode int, taskBody, containerBody string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "GET", r.Method, "HTTP verb should be GET")
+
+			w.Header().Set("Content-Type", "application/json")
+
+			switch path := r.URL.Path; path {
+			case "/task":
+				w.WriteHeader(statusCode)
+				w.Write([]byte(taskBody))
+
+			case "", "/":
+				w.WriteHeader(statusCode)
+				w.Write([]byte(containerBody))
+
+			default:
+				t.Errorf("unexpected URL: %s", path)
+			}
+		}))
+
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	fakeEcsTaskMetadataServer := func(t *testing.T, statusCode int, body string) *httptest.Server {
+		return fakeEcsMetadataServer(t, statusCode, body, "{}")
+	}
+
+	t.Run("does not retry container document", func(t *testing.T) {
+		var containerCalls, taskCalls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/task" {
+				taskCalls.Add(1)
+			} else {
+				containerCalls.Add(1)
+			}
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL)
+		client.Retries = 2
+
+		_, err := client.Fetch(context.Background())
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.Equal(t, int32(1), containerCalls.Load())
+		assert.Equal(t, int32(3), taskCalls.Load())
+	})
+
+	t.Run("when endpoint is not set", func(t *testing.T) {
+		metadata, err := newTestClient("").Fetch(context.Background())
+
+		assert.ErrorIs(t, err, ErrEndpointNotSet)
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when server returns payload with unknown fields in strict schema mode", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "cluster-name", "Wazzup": true }`)
+
+		client := newTestClient(server.URL)
+		client.StrictSchema = true
+
+		metadata, err := client.Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "cluster-name", metadata.EcsClusterName)
+	})
+
+	t.Run("when task path is customized", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			switch path := r.URL.Path; path {
+			case "/v4/deadbeef/task-metadata.json":
+				w.Write([]byte(`{ "Cluster": "cluster-name" }`))
+
+			case "/v4/deadbeef/":
+				w.Write([]byte(`{}`))
+
+			default:
+				t.Errorf("unexpected URL: %s", path)
+			}
+		}))
+
+		t.Cleanup(server.Close)
+
+		client := newTestClient(server.URL + "/v4/deadbeef/")
+		client.TaskPath = "task-metadata.json"
+
+		metadata, err := client.Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "cluster-name", metadata.EcsClusterName)
+	})
+
+	t.Run("when server returns error", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusInternalServerError, "he's not a messiah")
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.ErrorContains(t, err, "500 Internal Server Error")
+		assert.ErrorContains(t, err, "he's not a messiah")
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when server returns malformed payload", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, "he's a very very naughty boy")
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.ErrorIs(t, err, ErrMetadataDecode)
+		assert.ErrorContains(t, err, "he's a very very naughty boy")
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when server returns non-JSON payload", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>" + strings.Repeat("Always look on the bright side of life. ", 10) + "</body></html>"))
+		}))
+
+		t.Cleanup(server.Close)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.ErrorIs(t, err, ErrMetadataDecode)
+		assert.ErrorContains(t, err, `unexpected content type from metadata endpoint: "text/html"`)
+		assert.ErrorContains(t, err, "<html><body>Always look on the bright side of life.")
+		assert.NotContains(t, err.Error(), "</html>", "expected body to be truncated")
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when server returns valid payload with cluster name", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":          "cluster-name",
+				"TaskARN":			    "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"Family":           "task-family",
+				"Revision":         "161",
+				"ServiceName":      "service-name",
+				"DesiredStatus":    "RUNNING",
+				"KnownStatus":      "PENDING",
+				"AvailabilityZone": "aws-region-1a",
+				"LaunchType":       "FARGATE",
+				"Limits":           { "CPU": 0.25, "Memory": 512 }
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:            "aws-region-1",
+			AwsAccountID:         "123456789123",
+			AwsPartition:         "aws",
+			AwsAvailabilityZone:  "aws-region-1a",
+			EcsClusterName:       "cluster-name",
+			EcsClusterARN:        "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+			EcsServiceName:       "service-name",
+			EcsTaskFamily:        "task-family",
+			EcsTaskRevision:      "161",
+			EcsTaskDesiredStatus: "RUNNING",
+			EcsTaskKnownStatus:   "PENDING",
+			EcsTaskARN:           "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:            "deadbeef",
+			EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+			EcsLaunchType:        "FARGATE",
+			EcsTaskLimits:        TaskLimits{CPU: "0.25", Memory: "512"},
+		})
+	})
+
+	t.Run("when server returns valid payload with cluster name", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "arn:aws:ecs:aws-region-2:123456789123:cluster/cluster-name",
+				"TaskARN":			 "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"Family":        "task-family",
+				"Revision":      "161",
+				"ServiceName":   "service-name",
+				"DesiredStatus": "RUNNING"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:            "aws-region-1",
+			AwsAccountID:         "123456789123",
+			AwsPartition:         "aws",
+			EcsClusterName:       "cluster-name",
+			EcsClusterARN:        "arn:aws:ecs:aws-region-2:123456789123:cluster/cluster-name",
+			EcsServiceName:       "service-name",
+			EcsTaskFamily:        "task-family",
+			EcsTaskRevision:      "161",
+			EcsTaskDesiredStatus: "RUNNING",
+			EcsTaskARN:           "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:            "deadbeef",
+			EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+		})
+	})
+
+	t.Run("when server returns valid payload with bogus cluster ARN", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "wazzup/cluster-name",
+				"TaskARN":			 "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"Family":        "task-family",
+				"Revision":      "161",
+				"ServiceName":   "service-name",
+				"DesiredStatus": "RUNNING"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:            "aws-region-1",
+			AwsAccountID:         "123456789123",
+			AwsPartition:         "aws",
+			EcsClusterName:       "wazzup/cluster-name",
+			EcsServiceName:       "service-name",
+			EcsTaskFamily:        "task-family",
+			EcsTaskRevision:      "161",
+			EcsTaskDesiredStatus: "RUNNING",
+			EcsTaskARN:           "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:            "deadbeef",
+			EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+		})
+	})
+
+	t.Run("logs raw documents at debug level", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `{ "Cluster": "cluster-name" }`, `{ "DockerId": "deadbeef" }`)
+
+		var buf bytes.Buffer
+
+		oldLogger := slog.Default()
+		t.Cleanup(func() { slog.SetDefault(oldLogger) })
+
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+		_, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Contains(t, buf.String(), `msg="Retrieved ECS metadata document" path=/task body="{ \"Cluster\": \"cluster-name\" }"`)
+		assert.Contains(t, buf.String(), `msg="Retrieved ECS metadata document" path="" body="{ \"DockerId\": \"deadbeef\" }"`)
+	})
+
+	t.Run("logs error when cluster contains slash but is not an ARN", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `{ "Cluster": "wazzup/cluster-name" }`)
+
+		var buf bytes.Buffer
+
+		oldLogger := slog.Default()
+		t.Cleanup(func() { slog.SetDefault(oldLogger) })
+
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "wazzup/cluster-name", metadata.EcsClusterName)
+		assert.Contains(t, buf.String(), `level=ERROR msg="Malformed ECS Cluster`)
+		assert.Contains(t, buf.String(), "cluster=wazzup/cluster-name")
+	})
+
+	t.Run("when server returns valid payload from GovCloud", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"TaskARN":       "arn:aws-us-gov:ecs:us-gov-west-1:123456789123:task/cluster-name/deadbeef",
+				"LaunchType":    "FARGATE"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:      "us-gov-west-1",
+			AwsAccountID:   "123456789123",
+			AwsPartition:   "aws-us-gov",
+			EcsClusterName: "cluster-name",
+			EcsClusterARN:  "arn:aws-us-gov:ecs:us-gov-west-1:123456789123:cluster/cluster-name",
+			EcsTaskARN:     "arn:aws-us-gov:ecs:us-gov-west-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:      "deadbeef",
+			EcsLaunchType:  "FARGATE",
+		})
+	})
+
+	t.Run("when server returns valid payload with pull timestamps", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"TaskARN":       "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"PullStartedAt": "2020-10-02T00:43:06.202617438Z",
+				"PullStoppedAt": "2020-10-02T00:43:06.31319424Z"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, time.Date(2020, 10, 2, 0, 43, 6, 202617438, time.UTC), metadata.EcsPullStartedAt.UTC())
+		assert.Equal(t, time.Date(2020, 10, 2, 0, 43, 6, 313194240, time.UTC), metadata.EcsPullStoppedAt.UTC())
+	})
+
+	t.Run("when server returns valid payload with empty pull timestamps", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"TaskARN":       "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"PullStartedAt": "",
+				"PullStoppedAt": null
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.True(t, metadata.EcsPullStartedAt.IsZero())
+		assert.True(t, metadata.EcsPullStoppedAt.IsZero())
+	})
+
+	t.Run("when server returns valid payload with partial limits", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"TaskARN":       "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"LaunchType":    "FARGATE",
+				"Limits":        { "CPU": 2 }
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:      "aws-region-1",
+			AwsAccountID:   "123456789123",
+			AwsPartition:   "aws",
+			EcsClusterName: "cluster-name",
+			EcsClusterARN:  "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+			EcsTaskARN:     "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:      "deadbeef",
+			EcsLaunchType:  "FARGATE",
+			EcsTaskLimits:  TaskLimits{CPU: "2"},
+		})
+	})
+
+	t.Run("when server returns valid payload for EC2 launch type", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"TaskARN":       "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"Family":        "task-family",
+				"Revision":      "161",
+				"ServiceName":   "service-name",
+				"LaunchType":    "EC2"
+			}
+		`, `
+			{
+				"DockerId":             "cafebabe",
+				"ContainerInstanceARN": "arn:aws:ecs:aws-region-1:123456789123:container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:               "aws-region-1",
+			AwsAccountID:            "123456789123",
+			AwsPartition:            "aws",
+			EcsClusterName:          "cluster-name",
+			EcsClusterARN:           "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+			EcsServiceName:          "service-name",
+			EcsTaskFamily:           "task-family",
+			EcsTaskRevision:         "161",
+			EcsTaskARN:              "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:               "deadbeef",
+			EcsTaskDefinitionARN:    "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+			EcsLaunchType:           "EC2",
+			EcsContainerInstanceARN: "arn:aws:ecs:aws-region-1:123456789123:container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1",
+		})
+	})
+
+	t.Run("when server fails to return container metadata", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/task" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Cluster": "cluster-name", "LaunchType": "EC2"}`))
+		}))
+
+		t.Cleanup(server.Close)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			EcsClusterName: "cluster-name",
+			EcsLaunchType:  "EC2",
+		})
+	})
+
+	t.Run("when server returns valid payload with single container", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"LaunchType":    "FARGATE",
+				"Containers":    [
+					{ "DockerId": "cafebabe", "Name": "log_router", "Image": "fluent/fluent-bit:latest" }
+				]
+			}
+		`, `{ "DockerId": "cafebabe" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			EcsClusterName:   "cluster-name",
+			EcsLaunchType:    "FARGATE",
+			EcsContainerName: "log_router",
+			EcsImage:         "fluent/fluent-bit:latest",
+		})
+	})
+
+	t.Run("when server returns valid payload with multiple containers", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"LaunchType":    "FARGATE",
+				"Containers":    [
+					{ "DockerId": "deadbeef", "Name": "app", "Image": "app:latest", "ImageID": "sha256:deadbeef" },
+					{ "DockerId": "cafebabe", "Name": "log_router", "Image": "fluent/fluent-bit:latest", "ImageID": "fluent/fluent-bit@sha256:cafebabe" }
+				]
+			}
+		`, `{ "DockerId": "cafebabe" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			EcsClusterName:   "cluster-name",
+			EcsLaunchType:    "FARGATE",
+			EcsContainerName: "log_router",
+			EcsImage:         "fluent/fluent-bit:latest",
+			EcsImageDigest:   "sha256:cafebabe",
+		})
+	})
+
+	t.Run("when server returns valid payload with awsvpc network", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":    "cluster-name",
+				"LaunchType": "FARGATE",
+				"Containers": [
+					{
+						"DockerId": "deadbeef",
+						"Name":     "app",
+						"Image":    "app:latest",
+						"Networks": [{ "NetworkMode": "awsvpc", "IPv4Addresses": ["10.0.0.108"] }]
+					},
+					{
+						"DockerId": "cafebabe",
+						"Name":     "log_router",
+						"Image":    "fluent/fluent-bit:latest",
+						"Networks": [{ "NetworkMode": "awsvpc", "IPv4Addresses": ["10.0.0.108"] }]
+					}
+				]
+			}
+		`, `{ "DockerId": "cafebabe" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "10.0.0.108", metadata.EcsTaskIP)
+	})
+
+	t.Run("when server returns valid payload with multiple networks", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":    "cluster-name",
+				"LaunchType": "EC2",
+				"Containers": [
+					{
+						"DockerId": "deadbeef",
+						"Name":     "app",
+						"Networks": [{ "NetworkMode": "bridge", "IPv4Addresses": ["172.17.0.3"] }]
+					},
+					{
+						"DockerId": "cafebabe",
+						"Name":     "log_router",
+						"Networks": [
+							{ "NetworkMode": "bridge", "IPv4Addresses": [] },
+							{ "NetworkMode": "bridge", "IPv4Addresses": ["172.17.0.2", "172.18.0.2"] }
+						]
+					}
+				]
+			}
+		`, `{
+			"DockerId": "cafebabe",
+			"Networks": [
+				{ "NetworkMode": "bridge", "IPv4Addresses": [] },
+				{ "NetworkMode": "bridge", "IPv4Addresses": ["172.17.0.2", "172.18.0.2"] }
+			]
+		}`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "172.17.0.2", metadata.EcsTaskIP, "expected current container's address")
+	})
+
+	t.Run("when server returns valid payload without networks", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":    "cluster-name",
+				"Containers": [{ "DockerId": "cafebabe", "Name": "log_router", "Networks": [] }]
+			}
+		`, `{ "DockerId": "cafebabe" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Empty(t, metadata.EcsTaskIP)
+	})
+
+	t.Run("when container document describes the current container", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"Containers":    [
+					{ "DockerId": "cafebabe", "Name": "stale", "Image": "stale:latest" }
+				]
+			}
+		`, `{ "DockerId": "cafebabe", "Name": "log_router", "Image": "fluent/fluent-bit:latest", "ImageID": "sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "cluster-name", metadata.EcsClusterName, "expected task-level value from task document")
+		assert.Equal(t, "log_router", metadata.EcsContainerName, "expected container-level value from container document")
+		assert.Equal(t, "fluent/fluent-bit:latest", metadata.EcsImage)
+		assert.Equal(t, "sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de", metadata.EcsImageDigest)
+	})
+
+	t.Run("when current container is not among task containers", func(t *testing.T) {
+		server := fakeEcsMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"LaunchType":    "FARGATE",
+				"Containers":    [
+					{ "DockerId": "deadbeef", "Name": "app", "Image": "app:latest" }
+				]
+			}
+		`, `{ "DockerId": "cafebabe" }`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			EcsClusterName: "cluster-name",
+			EcsLaunchType:  "FARGATE",
+		})
+	})
+
+	t.Run("when server returns valid payload without cluster", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":    "",
+				"TaskARN":    "arn:aws:ecs:aws-region-1:123456789123:task/my-cluster/abc123",
+				"LaunchType": "FARGATE"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			AwsRegion:      "aws-region-1",
+			AwsAccountID:   "123456789123",
+			AwsPartition:   "aws",
+			EcsClusterName: "my-cluster",
+			EcsClusterARN:  "arn:aws:ecs:aws-region-1:123456789123:cluster/my-cluster",
+			EcsTaskARN:     "arn:aws:ecs:aws-region-1:123456789123:task/my-cluster/abc123",
+			EcsTaskID:      "abc123",
+			EcsLaunchType:  "FARGATE",
+		})
+	})
+
+	t.Run("when server returns valid payload without cluster and with old task ARN format", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"TaskARN":    "arn:aws:ecs:aws-region-1:123456789123:task/abc123",
+				"LaunchType": "EC2"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, "", metadata.EcsClusterName)
+		assert.Equal(t, "abc123", metadata.EcsTaskID)
+	})
+
+	t.Run("when server returns valid payload with bogus task ARN", func(t *testing.T) {
+		server := fakeEcsTaskMetadataServer(t, http.StatusOK, `
+			{
+				"Cluster":       "cluster-name",
+				"TaskARN":       "wazzup/deadbeef",
+				"Family":        "task-family",
+				"Revision":      "161",
+				"ServiceName":   "service-name",
+				"DesiredStatus": "RUNNING"
+			}
+		`)
+
+		metadata, err := newTestClient(server.URL).Fetch(context.Background())
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, metadata, &Metadata{
+			EcsClusterName:       "cluster-name",
+			EcsServiceName:       "service-name",
+			EcsTaskFamily:        "task-family",
+			EcsTaskRevision:      "161",
+			EcsTaskDesiredStatus: "RUNNING",
+			EcsTaskARN:           "wazzup/deadbeef",
+		})
+	})
+}
+
+func TestReadFile(t *testing.T) {
+	writeFile := func(t *testing.T, body string) string {
+		path := filepath.Join(t.TempDir(), "task.json")
+
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("when file does not exist", func(t *testing.T) {
+		metadata, err := ReadFile(filepath.Join(t.TempDir(), "missing.json"))
+
+		assert.ErrorIs(t, err, ErrMetadataUnavailable)
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when file is not valid JSON", func(t *testing.T) {
+		metadata, err := ReadFile(writeFile(t, "he's not a messiah"))
+
+		assert.ErrorIs(t, err, ErrMetadataDecode)
+		assert.Nil(t, metadata, "expected metadata to be nil")
+	})
+
+	t.Run("when file is valid task metadata", func(t *testing.T) {
+		metadata, err := ReadFile(writeFile(t, `
+			{
+				"Cluster":  "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+				"TaskARN":  "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+				"Family":   "task-family",
+				"Revision": "161"
+			}
+		`))
+
+		assert.Nil(t, err, "expected no error")
+		assert.Equal(t, &Metadata{
+			AwsRegion:            "aws-region-1",
+			AwsAccountID:         "123456789123",
+			AwsPartition:         "aws",
+			EcsClusterName:       "cluster-name",
+			EcsClusterARN:        "arn:aws:ecs:aws-region-1:123456789123:cluster/cluster-name",
+			EcsTaskFamily:        "task-family",
+			EcsTaskRevision:      "161",
+			EcsTaskARN:           "arn:aws:ecs:aws-region-1:123456789123:task/cluster-name/deadbeef",
+			EcsTaskID:            "deadbeef",
+			EcsTaskDefinitionARN: "arn:aws:ecs:aws-region-1:123456789123:task-definition/task-family:161",
+		}, metadata)
+	})
+}
@@ -0,0 +1,361 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+// Package ecsmeta retrieves ECS task metadata from the task metadata endpoint
+// v4, and exposes it as environment variables.
+//
+// See: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-metadata-endpoint-v4.html
+package ecsmeta
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// See: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-metadata-endpoint-v4-response.html
+type Metadata struct {
+	AwsRegion            string
+	AwsAvailabilityZone  string `json:"AvailabilityZone"` // AWS Availability Zone
+	AwsAccountID         string
+	AwsPartition         string
+	EcsClusterName       string `json:"Cluster"`     // ECS Cluster Name
+	EcsServiceName       string `json:"ServiceName"` // ECS Service Name
+	EcsTaskFamily        string `json:"Family"`      // ECS Task Family
+	EcsTaskRevision      string `json:"Revision"`    // ECS Task Revision
+	EcsTaskARN           string `json:"TaskARN"`     // ECS Task ARN
+	EcsTaskID            string
+	EcsTaskDefinitionARN string     // ECS Task Definition ARN, derived from Task ARN, Family and Revision
+	EcsClusterARN        string     // ECS Cluster ARN, given as Cluster or built from Task ARN and Cluster Name
+	EcsLaunchType        string     `json:"LaunchType"` // ECS Launch Type (EC2, FARGATE or EXTERNAL)
+	EcsTaskLimits        TaskLimits `json:"Limits"`     // ECS Task resource limits
+
+	EcsTaskDesiredStatus string `json:"DesiredStatus"` // ECS Task Desired Status
+	EcsTaskKnownStatus   string `json:"KnownStatus"`   // ECS Task Known Status
+
+	EcsPullStartedAt Timestamp `json:"PullStartedAt,omitzero"` // When the first image pull of the task started
+	EcsPullStoppedAt Timestamp `json:"PullStoppedAt,omitzero"` // When the last image pull of the task finished
+
+	EcsContainerInstanceARN string // ECS Container Instance ARN (EC2 launch type only)
+
+	EcsContainerName string // Name of the current container
+	EcsImage         string // Image of the current container
+	EcsImageDigest   string // Image digest of the current container, e.g. sha256:...
+
+	EcsTaskIP string // Primary private IPv4 address of the task
+
+	EcsLogGroup      string // Log group name, rendered from a template with LogGroup
+	EcsDeploymentKey string // Deployment key, rendered from a template with DeploymentKey
+
+	EcsServiceDesiredCount string // Desired count of the ECS service, retrieved from ECS API rather than the endpoint
+
+	// EC2 Instance ID (EC2 launch type only), retrieved from ECS API rather
+	// than the endpoint, as neither of metadata documents includes it.
+	Ec2InstanceID string
+}
+
+// Task-level resource limits. Either of them might be absent.
+type TaskLimits struct {
+	CPU    json.Number `json:",omitempty"` // vCPUs
+	Memory json.Number `json:",omitempty"` // MiB
+}
+
+// Point in time reported by the endpoint. Absent, null or empty values are
+// decoded as zero time.
+type Timestamp struct {
+	time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if s := string(data); s == "null" || s == `""` {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	return t.Time.UnmarshalJSON(data)
+}
+
+// Returns timestamp in RFC3339 format, or an empty string if it's zero.
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Container-level metadata document, served by the root of the endpoint.
+type Container struct {
+	DockerID             string `json:"DockerId"`
+	Name                 string
+	Image                string
+	ImageID              string
+	ContainerInstanceARN string
+	Networks             []Network
+}
+
+// Network the container is attached to.
+type Network struct {
+	NetworkMode   string
+	IPv4Addresses []string
+}
+
+// Task metadata document, served by the `/task` path of the endpoint.
+type taskDocument struct {
+	Metadata
+	Containers []Container
+}
+
+// Names of environment variables returned by Environ, in the same order.
+// ECS_SERVICE_DESIRED_COUNT and EC2_INSTANCE_ID are empty, unless retrieved
+// from ECS API by the caller.
+var EnvKeys = []string{
+	"AWS_REGION",
+	"AWS_AVAILABILITY_ZONE",
+	"AWS_ACCOUNT_ID",
+	"AWS_PARTITION",
+	"ECS_CLUSTER_NAME",
+	"ECS_CLUSTER_ARN",
+	"ECS_SERVICE_NAME",
+	"ECS_TASK_FAMILY",
+	"ECS_TASK_REVISION",
+	"ECS_TASK_ARN",
+	"ECS_TASK_ID",
+	"ECS_TASK_DEFINITION_ARN",
+	"ECS_LAUNCH_TYPE",
+	"ECS_CONTAINER_INSTANCE_ARN",
+	"ECS_TASK_CPU_LIMIT",
+	"ECS_TASK_MEMORY_LIMIT",
+	"ECS_CONTAINER_NAME",
+	"ECS_IMAGE",
+	"ECS_IMAGE_DIGEST",
+	"ECS_TASK_DESIRED_STATUS",
+	"ECS_TASK_KNOWN_STATUS",
+	"ECS_PULL_STARTED_AT",
+	"ECS_PULL_STOPPED_AT",
+	"ECS_TASK_IP",
+	"ECS_LOG_GROUP",
+	"ECS_DEPLOYMENT_KEY",
+	"ECS_SERVICE_DESIRED_COUNT",
+	"EC2_INSTANCE_ID",
+}
+
+// Returns metadata as `KEY=VALUE` pairs, one per each of EnvKeys. Values of
+// unknown metadata are empty.
+func (m *Metadata) Environ() []string {
+	values := []string{
+		m.AwsRegion,
+		m.AwsAvailabilityZone,
+		m.AwsAccountID,
+		m.AwsPartition,
+		m.EcsClusterName,
+		m.EcsClusterARN,
+		m.EcsServiceName,
+		m.EcsTaskFamily,
+		m.EcsTaskRevision,
+		m.EcsTaskARN,
+		m.EcsTaskID,
+		m.EcsTaskDefinitionARN,
+		m.EcsLaunchType,
+		m.EcsContainerInstanceARN,
+		m.EcsTaskLimits.CPU.String(),
+		m.EcsTaskLimits.Memory.String(),
+		m.EcsContainerName,
+		m.EcsImage,
+		m.EcsImageDigest,
+		m.EcsTaskDesiredStatus,
+		m.EcsTaskKnownStatus,
+		m.EcsPullStartedAt.String(),
+		m.EcsPullStoppedAt.String(),
+		m.EcsTaskIP,
+		m.EcsLogGroup,
+		m.EcsDeploymentKey,
+		m.EcsServiceDesiredCount,
+		m.Ec2InstanceID,
+	}
+
+	environ := make([]string, len(EnvKeys))
+
+	for i, key := range EnvKeys {
+		environ[i] = key + "=" + values[i]
+	}
+
+	return environ
+}
+
+// Default template of log group names, following the common `/ecs/` convention.
+const DefaultLogGroupTemplate = "/ecs/{cluster}/{service}"
+
+// Default template of deployment keys: tasks of the same service revision share
+// the key.
+const DefaultDeploymentKeyTemplate = "{cluster}/{service}/{family}:{revision}"
+
+// Returns log group name rendered from `template`, with placeholders replaced
+// by metadata values: `{cluster}`, `{service}`, `{family}`, `{revision}`,
+// `{task_id}`, `{container}`, `{region}` and `{account_id}`. Unknown values are
+// replaced with `unknown`.
+func (m *Metadata) LogGroup(template string) string {
+	return m.render(template)
+}
+
+// Returns deployment key rendered from `template`, the same way as LogGroup
+// does. Standalone tasks, not launched by a service, have no deployment key.
+func (m *Metadata) DeploymentKey(template string) string {
+	if m.EcsServiceName == "" {
+		return ""
+	}
+
+	return m.render(template)
+}
+
+// Returns `template` with placeholders replaced by metadata values.
+func (m *Metadata) render(template string) string {
+	value := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+
+		return s
+	}
+
+	return strings.NewReplacer(
+		"{cluster}", value(m.EcsClusterName),
+		"{service}", value(m.EcsServiceName),
+		"{family}", value(m.EcsTaskFamily),
+		"{revision}", value(m.EcsTaskRevision),
+		"{task_id}", value(m.EcsTaskID),
+		"{container}", value(m.EcsContainerName),
+		"{region}", value(m.AwsRegion),
+		"{account_id}", value(m.AwsAccountID),
+	).Replace(template)
+}
+
+// Returns the entry of `containers` describing the current container, matched
+// by its Docker ID, or nil if there's no such entry.
+func findCurrentContainer(containers []Container, dockerID string) *Container {
+	if dockerID == "" {
+		return nil
+	}
+
+	for i := range containers {
+		if containers[i].DockerID == dockerID {
+			return &containers[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns the last segment of the ARN resource, which can be delimited by
+// either `/` (e.g. `task/cluster-name/deadbeef`) or `:` (e.g. `task:deadbeef`).
+// Returns empty string when resource is empty or ends with a delimiter.
+func lastArnPart(arn arn.ARN) string {
+	return arn.Resource[strings.LastIndexAny(arn.Resource, "/:")+1:]
+}
+
+// Returns the first IPv4 address of the first of `containers` attached to any
+// network, or empty string if there's none. In awsvpc network mode, all of the
+// task's containers share the same single address.
+func firstIPv4Address(containers ...Container) string {
+	for _, container := range containers {
+		for _, network := range container.Networks {
+			for _, address := range network.IPv4Addresses {
+				if address != "" {
+					return address
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// Returns task definition ARN, built from the task ARN's partition, region
+// and account along with task family and revision. Returns empty string if
+// any of them is unknown.
+func taskDefinitionArn(taskARN arn.ARN, family, revision string) string {
+	if taskARN.Partition == "" || taskARN.Region == "" || taskARN.AccountID == "" || family == "" || revision == "" {
+		return ""
+	}
+
+	return arn.ARN{
+		Partition: taskARN.Partition,
+		Service:   "ecs",
+		Region:    taskARN.Region,
+		AccountID: taskARN.AccountID,
+		Resource:  "task-definition/" + family + ":" + revision,
+	}.String()
+}
+
+// Returns cluster ARN, built from the task ARN's partition, region and account
+// along with cluster name. Returns empty string if any of them is unknown, or
+// the name is not a valid cluster name.
+func clusterArn(taskARN arn.ARN, name string) string {
+	if taskARN.Partition == "" || taskARN.Region == "" || taskARN.AccountID == "" || name == "" || strings.Contains(name, "/") {
+		return ""
+	}
+
+	return arn.ARN{
+		Partition: taskARN.Partition,
+		Service:   "ecs",
+		Region:    taskARN.Region,
+		AccountID: taskARN.AccountID,
+		Resource:  "cluster/" + name,
+	}.String()
+}
+
+// Returns cluster name from the task ARN resource of the new (long) format,
+// `task/cluster-name/task-id`. Returns empty string for the old format,
+// `task/task-id`, which doesn't include the cluster name.
+func clusterNameFromTaskArn(taskARN arn.ARN) string {
+	parts := strings.Split(taskARN.Resource, "/")
+
+	if len(parts) != 3 || parts[0] != "task" {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// Returns image digest (e.g. `sha256:...`) given container's `ImageID`, which
+// might be prefixed with the repository (e.g. `repo@sha256:...`). Returns an
+// empty string if `ImageID` is not a digest.
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		imageID = imageID[i+1:]
+	}
+
+	algorithm, encoded, ok := strings.Cut(imageID, ":")
+
+	if !ok || algorithm == "" || encoded == "" {
+		return ""
+	}
+
+	for _, c := range encoded {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return ""
+		}
+	}
+
+	return imageID
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2025 Alexey Zapparov <alexey@zapparov.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package ecsmeta
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCurrentContainer(t *testing.T) {
+	app := Container{DockerID: "deadbeef", Name: "app"}
+	logRouter := Container{DockerID: "cafebabe", Name: "log_router"}
+
+	t.Run("matches container by Docker ID", func(t *testing.T) {
+		assert.Equal(t, &logRouter, findCurrentContainer([]Container{app, logRouter}, "cafebabe"))
+		assert.Equal(t, &logRouter, findCurrentContainer([]Container{logRouter}, "cafebabe"))
+		assert.Nil(t, findCurrentContainer([]Container{app}, "cafebabe"))
+	})
+
+	t.Run("when Docker ID is unknown", func(t *testing.T) {
+		assert.Nil(t, findCurrentContainer([]Container{app}, ""))
+		assert.Nil(t, findCurrentContainer([]Container{app, logRouter}, ""))
+		assert.Nil(t, findCurrentContainer(nil, ""))
+	})
+}
+
+func TestMetadata_Environ(t *testing.T) {
+	t.Run("returns value for each of EnvKeys", func(t *testing.T) {
+		metadata := Metadata{EcsClusterName: "cluster-name", EcsTaskLimits: TaskLimits{CPU: "0.25"}}
+		environ := metadata.Environ()
+
+		assert.Len(t, environ, len(EnvKeys))
+
+		for i, key := range EnvKeys {
+			assert.True(t, strings.HasPrefix(environ[i], key+"="), "expected %q to be %s", environ[i], key)
+		}
+
+		assert.Contains(t, environ, "ECS_CLUSTER_NAME=cluster-name")
+		assert.Contains(t, environ, "ECS_TASK_CPU_LIMIT=0.25")
+		assert.Contains(t, environ, "ECS_TASK_MEMORY_LIMIT=")
+		assert.Contains(t, environ, "ECS_PULL_STARTED_AT=")
+	})
+
+	t.Run("returns timestamps in RFC3339", func(t *testing.T) {
+		pulledAt := time.Date(2020, 10, 2, 2, 43, 6, 202617438, time.FixedZone("CEST", 2*60*60))
+		metadata := Metadata{EcsPullStartedAt: Timestamp{Time: pulledAt}}
+
+		assert.Contains(t, metadata.Environ(), "ECS_PULL_STARTED_AT=2020-10-02T00:43:06Z")
+	})
+}
+
+func TestMetadata_LogGroup(t *testing.T) {
+	metadata := Metadata{
+		AwsRegion:        "aws-region-1",
+		AwsAccountID:     "123456789123",
+		EcsClusterName:   "cluster-name",
+		EcsServiceName:   "service-name",
+		EcsTaskFamily:    "task-family",
+		EcsTaskRevision:  "161",
+		EcsTaskID:        "deadbeef",
+		EcsContainerName: "log_router",
+	}
+
+	t.Run("renders default template", func(t *testing.T) {
+		assert.Equal(t, "/ecs/cluster-name/service-name", metadata.LogGroup(DefaultLogGroupTemplate))
+	})
+
+	t.Run("renders all placeholders", func(t *testing.T) {
+		assert.Equal(t,
+			"aws-region-1/123456789123/cluster-name/service-name/task-family:161/deadbeef/log_router",
+			metadata.LogGroup("{region}/{account_id}/{cluster}/{service}/{family}:{revision}/{task_id}/{container}"))
+	})
+
+	t.Run("substitutes unknown values", func(t *testing.T) {
+		assert.Equal(t, "/ecs/cluster-name/unknown", (&Metadata{EcsClusterName: "cluster-name"}).LogGroup(DefaultLogGroupTemplate))
+		assert.Equal(t, "/ecs/unknown/unknown", (&Metadata{}).LogGroup(DefaultLogGroupTemplate))
+	})
+
+	t.Run("leaves text without placeholders intact", func(t *testing.T) {
+		assert.Equal(t, "/aws/ecs/{unsupported}", metadata.LogGroup("/aws/ecs/{unsupported}"))
+	})
+}
+
+func TestMetadata_DeploymentKey(t *testing.T) {
+	t.Run("renders default template", func(t *testing.T) {
+		metadata := Metadata{EcsClusterName: "cluster-name", EcsServiceName: "service-name", EcsTaskFamily: "task-family", EcsTaskRevision: "161"}
+
+		assert.Equal(t, "cluster-name/service-name/task-family:161", metadata.DeploymentKey(DefaultDeploymentKeyTemplate))
+		assert.Equal(t, "service-name-161", metadata.DeploymentKey("{service}-{revision}"))
+	})
+
+	t.Run("substitutes unknown values", func(t *testing.T) {
+		metadata := Metadata{EcsServiceName: "service-name"}
+
+		assert.Equal(t, "unknown/service-name/unknown:unknown", metadata.DeploymentKey(DefaultDeploymentKeyTemplate))
+	})
+
+	t.Run("returns empty string for standalone tasks", func(t *testing.T) {
+		metadata := Metadata{EcsClusterName: "cluster-name", EcsTaskFamily: "task-family", EcsTaskRevision: "161"}
+
+		assert.Equal(t, "", metadata.DeploymentKey(DefaultDeploymentKeyTemplate))
+	})
+}
+
+func TestLastArnPart(t *testing.T) {
+	resourceOf := func(resource string) arn.ARN {
+		return arn.ARN{Partition: "aws", Service: "ecs", Region: "aws-region-1", AccountID: "123456789123", Resource: resource}
+	}
+
+	t.Run("returns last segment of slash-delimited resource", func(t *testing.T) {
+		assert.Equal(t, "deadbeef", lastArnPart(resourceOf("task/cluster-name/deadbeef")))
+		assert.Equal(t, "cluster-name", lastArnPart(resourceOf("cluster/cluster-name")))
+		assert.Equal(t, "1f73d099b6a24dd7b0f3e3b8a3a2c2d1", lastArnPart(resourceOf("container-instance/cluster-name/1f73d099b6a24dd7b0f3e3b8a3a2c2d1")))
+	})
+
+	t.Run("returns last segment of colon-delimited resource", func(t *testing.T) {
+		assert.Equal(t, "deadbeef", lastArnPart(resourceOf("task:deadbeef")))
+		assert.Equal(t, "deadbeef", lastArnPart(resourceOf("task:cluster-name/deadbeef")))
+	})
+
+	t.Run("returns resource without delimiters as is", func(t *testing.T) {
+		assert.Equal(t, "deadbeef", lastArnPart(resourceOf("deadbeef")))
+	})
+
+	t.Run("returns empty string when there's no last segment", func(t *testing.T) {
+		assert.Equal(t, "", lastArnPart(resourceOf("")))
+		assert.Equal(t, "", lastArnPart(resourceOf("task/")))
+		assert.Equal(t, "", lastArnPart(resourceOf("task:")))
+	})
+}
+
+func TestTaskDefinitionArn(t *testing.T) {
+	taskARN := arn.ARN{Partition: "aws-us-gov", Service: "ecs", Region: "us-gov-west-1", AccountID: "123456789123", Resource: "task/cluster-name/deadbeef"}
+
+	t.Run("builds ARN from task ARN, family and revision", func(t *testing.T) {
+		assert.Equal(t, "arn:aws-us-gov:ecs:us-gov-west-1:123456789123:task-definition/task-family:161", taskDefinitionArn(taskARN, "task-family", "161"))
+	})
+
+	t.Run("returns empty string when any component is missing", func(t *testing.T) {
+		assert.Equal(t, "", taskDefinitionArn(taskARN, "", "161"))
+		assert.Equal(t, "", taskDefinitionArn(taskARN, "task-family", ""))
+		assert.Equal(t, "", taskDefinitionArn(arn.ARN{Partition: "aws", Region: "aws-region-1"}, "task-family", "161"))
+	})
+}
+
+func TestClusterArn(t *testing.T) {
+	taskARN := arn.ARN{Partition: "aws-us-gov", Service: "ecs", Region: "us-gov-west-1", AccountID: "123456789123", Resource: "task/cluster-name/deadbeef"}
+
+	t.Run("builds ARN from task ARN and cluster name", func(t *testing.T) {
+		assert.Equal(t, "arn:aws-us-gov:ecs:us-gov-west-1:123456789123:cluster/cluster-name", clusterArn(taskARN, "cluster-name"))
+	})
+
+	t.Run("returns empty string when any component is missing", func(t *testing.T) {
+		assert.Equal(t, "", clusterArn(taskARN, ""))
+		assert.Equal(t, "", clusterArn(arn.ARN{}, "cluster-name"))
+	})
+
+	t.Run("returns empty string for malformed cluster name", func(t *testing.T) {
+		assert.Equal(t, "", clusterArn(taskARN, "wazzup/cluster-name"))
+	})
+}
+
+func TestImageDigest(t *testing.T) {
+	t.Run("returns digest as is", func(t *testing.T) {
+		assert.Equal(t, "sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de", imageDigest("sha256:2ae34abc2ed0a22e280d17e13f9c01aaf725688b09b7a1525d1a2750e2c0d1de"))
+	})
+
+	t.Run("strips repository prefix", func(t *testing.T) {
+		assert.Equal(t, "sha256:cafebabe", imageDigest("fluent/fluent-bit@sha256:cafebabe"))
+		assert.Equal(t, "sha256:cafebabe", imageDigest("123456789123.dkr.ecr.aws-region-1.amazonaws.com/fluent-bit@sha256:cafebabe"))
+	})
+
+	t.Run("returns empty string when ImageID is not a digest", func(t *testing.T) {
+		assert.Equal(t, "", imageDigest(""))
+		assert.Equal(t, "", imageDigest("fluent/fluent-bit:latest"))
+		assert.Equal(t, "", imageDigest("sha256:"))
+		assert.Equal(t, "", imageDigest(":cafebabe"))
+	})
+}